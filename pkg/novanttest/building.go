@@ -0,0 +1,229 @@
+// Package novanttest provides an in-process fake of the Novant REST API for
+// tests and local development. NewServer serves a synthetic building over
+// httptest, reproducing the quirks of the real API that the plugin has to
+// cope with: gzip-encoded error bodies, numeric device_id values, dynamic
+// per-point keys in trend rows, 429 rate limiting, and ETag /
+// Last-Modified validators with 304 Not Modified on the metadata endpoints.
+package novanttest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Building is the synthetic project served by a Server. Build one with
+// NewBuilding, or fill it in by hand for a specific scenario. It must not be
+// modified once the server has started.
+type Building struct {
+	ProjID   int
+	ProjName string
+	City     string
+	Tz       string
+	Usage    int
+	Capacity int
+
+	Zones   []Zone
+	Spaces  []Space
+	Assets  []Asset
+	Sources []Source
+}
+
+type Zone struct {
+	ID            string
+	Name          string
+	Type          string
+	FedByAssetIDs []string
+	FeedsSpaceIDs []string
+}
+
+type Space struct {
+	ID               string
+	Name             string
+	Type             string
+	ParentZoneID     string
+	ContainsAssetIDs []string
+}
+
+type Asset struct {
+	ID        string
+	Name      string
+	Type      string
+	SpaceID   string
+	SourceIDs []string
+}
+
+// Source is a device. DeviceID is served as a bare JSON number when
+// NumericDeviceID is set, as the real API does for BACnet sources.
+type Source struct {
+	ID              string
+	Name            string
+	Type            string
+	Addr            string
+	DeviceID        int
+	NumericDeviceID bool
+	Enabled         bool
+	Bound           bool
+	ParentAssetID   string
+	Points          []Point
+}
+
+// Point is a source point. Kind is "num", "bool", or "enum"; Sample
+// supplies its value at a given time (nil for no sample), and defaults to a
+// deterministic waveform derived from the point's kind and position.
+type Point struct {
+	ID       string
+	Name     string
+	Type     string
+	Kind     string
+	Unit     string
+	Writable bool
+	Sample   func(t time.Time) interface{}
+}
+
+// Options sizes a synthetic building. Zero fields take the defaults noted.
+type Options struct {
+	Zones           int    // default 2
+	SpacesPerZone   int    // default 2
+	AssetsPerSpace  int    // default 1
+	PointsPerSource int    // default 6
+	Tz              string // default "America/New_York"
+}
+
+// NewBuilding returns a synthetic building: zones feeding spaces, one asset
+// per space by default, one source per asset, and a mix of numeric, bool,
+// and enum points on each source. Every third source is a BACnet device with
+// a numeric device_id, and every fourth source is unbound.
+func NewBuilding(opts Options) *Building {
+	if opts.Zones == 0 {
+		opts.Zones = 2
+	}
+	if opts.SpacesPerZone == 0 {
+		opts.SpacesPerZone = 2
+	}
+	if opts.AssetsPerSpace == 0 {
+		opts.AssetsPerSpace = 1
+	}
+	if opts.PointsPerSource == 0 {
+		opts.PointsPerSource = 6
+	}
+	if opts.Tz == "" {
+		opts.Tz = "America/New_York"
+	}
+
+	b := &Building{
+		ProjID:   1,
+		ProjName: "Test Building",
+		City:     "Richmond",
+		Tz:       opts.Tz,
+		Capacity: 10000,
+	}
+	var space, asset int
+	for z := 1; z <= opts.Zones; z++ {
+		zone := Zone{ID: fmt.Sprintf("z.%d", z), Name: fmt.Sprintf("Zone %d", z), Type: "hvac"}
+		for i := 0; i < opts.SpacesPerZone; i++ {
+			space++
+			sp := Space{
+				ID:           fmt.Sprintf("sp.%d", space),
+				Name:         fmt.Sprintf("Room %d", 100+space),
+				Type:         "room",
+				ParentZoneID: zone.ID,
+			}
+			zone.FeedsSpaceIDs = append(zone.FeedsSpaceIDs, sp.ID)
+			for j := 0; j < opts.AssetsPerSpace; j++ {
+				asset++
+				a := Asset{
+					ID:      fmt.Sprintf("a.%d", asset),
+					Name:    fmt.Sprintf("VAV-%d", asset),
+					Type:    "vav",
+					SpaceID: sp.ID,
+				}
+				src := newSource(asset, a.ID, opts.PointsPerSource)
+				a.SourceIDs = []string{src.ID}
+				sp.ContainsAssetIDs = append(sp.ContainsAssetIDs, a.ID)
+				zone.FedByAssetIDs = append(zone.FedByAssetIDs, a.ID)
+				b.Assets = append(b.Assets, a)
+				b.Sources = append(b.Sources, src)
+				b.Usage += len(src.Points)
+			}
+			b.Spaces = append(b.Spaces, sp)
+		}
+		b.Zones = append(b.Zones, zone)
+	}
+	return b
+}
+
+func newSource(n int, assetID string, points int) Source {
+	src := Source{
+		ID:            fmt.Sprintf("s.%d", n),
+		Name:          fmt.Sprintf("VAV-%d Controller", n),
+		Type:          "modbus",
+		Addr:          fmt.Sprintf("10.0.0.%d", n),
+		DeviceID:      n,
+		Enabled:       true,
+		Bound:         n%4 != 0,
+		ParentAssetID: assetID,
+	}
+	if n%3 == 0 {
+		src.Type = "bacnet"
+		src.DeviceID = 1000 + n
+		src.NumericDeviceID = true
+	}
+	for i := 1; i <= points; i++ {
+		p := Point{ID: fmt.Sprintf("%s.%d", src.ID, i), Writable: i == 1}
+		switch {
+		case i%6 == 5:
+			p.Name, p.Type, p.Kind = "Occupied", "bi", "bool"
+		case i%6 == 0:
+			p.Name, p.Type, p.Kind = "Mode", "mv", "enum"
+		default:
+			p.Name, p.Type, p.Kind, p.Unit = fmt.Sprintf("Temp %d", i), "ai", "num", "°F"
+		}
+		p.Sample = defaultSample(n*100+i, p.Kind)
+		src.Points = append(src.Points, p)
+	}
+	return src
+}
+
+// defaultSample returns a deterministic waveform: numeric points follow a
+// daily sine around 70 with a gap every 97th five-minute slot, bool points
+// are on during working hours, and enum points cycle through modes.
+func defaultSample(seed int, kind string) func(time.Time) interface{} {
+	return func(t time.Time) interface{} {
+		switch kind {
+		case "bool":
+			return t.Hour() >= 8 && t.Hour() < 18
+		case "enum":
+			return []string{"off", "heat", "cool"}[(t.Hour()/8)%3]
+		}
+		slot := t.Unix() / 300
+		if (slot+int64(seed))%97 == 0 {
+			return nil
+		}
+		day := float64(t.Hour()*60+t.Minute()) / (24 * 60)
+		v := 70 + 5*math.Sin(2*math.Pi*day+float64(seed)) + float64(seed%10)/10
+		return math.Round(v*100) / 100
+	}
+}
+
+// point returns the point with the given ID and its source.
+func (b *Building) point(id string) (*Point, *Source) {
+	for i := range b.Sources {
+		s := &b.Sources[i]
+		for j := range s.Points {
+			if s.Points[j].ID == id {
+				return &s.Points[j], s
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (b *Building) source(id string) *Source {
+	for i := range b.Sources {
+		if b.Sources[i].ID == id {
+			return &b.Sources[i]
+		}
+	}
+	return nil
+}
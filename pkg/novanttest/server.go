@@ -0,0 +1,524 @@
+package novanttest
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake Novant API serving a Building. Requests must carry the
+// server's API key as the basic auth user, as with the real API.
+type Server struct {
+	*httptest.Server
+
+	Building *Building
+	APIKey   string
+	// NaiveTimestamps serves trend row timestamps without a UTC offset, as
+	// wall-clock times in the building's time zone, as some API responses
+	// do. Across the end of DST the repeated hour then appears twice with
+	// the same timestamp. Set it before the first request.
+	NaiveTimestamps bool
+
+	// modified is the Last-Modified time of every metadata response. The
+	// building is fixed once the server starts, so it never changes.
+	modified time.Time
+
+	mu          sync.Mutex
+	requests    map[string]int
+	notModified map[string]int
+	failures    map[string][]int
+}
+
+// NewServer starts a fake API for b that accepts apiKey. Callers must Close
+// it when done.
+func NewServer(b *Building, apiKey string) *Server {
+	s := &Server{
+		Building:    b,
+		APIKey:      apiKey,
+		modified:    time.Now().UTC().Truncate(time.Second),
+		requests:    make(map[string]int),
+		notModified: make(map[string]int),
+		failures:    make(map[string][]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/project", s.handleProject)
+	mux.HandleFunc("/v1/zones", s.handleZones)
+	mux.HandleFunc("/v1/spaces", s.handleSpaces)
+	mux.HandleFunc("/v1/assets", s.handleAssets)
+	mux.HandleFunc("/v1/sources", s.handleSources)
+	mux.HandleFunc("/v1/points", s.handlePoints)
+	mux.HandleFunc("/v1/values", s.handleValues)
+	mux.HandleFunc("/v1/trends", s.handleTrends)
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// FailNext makes the next len(statuses) requests to path fail with the given
+// HTTP statuses, in order. A 429 carries "Retry-After: 1".
+func (s *Server) FailNext(path string, statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], statuses...)
+}
+
+// RateLimit makes the next n requests to path fail with 429 Too Many
+// Requests.
+func (s *Server) RateLimit(path string, n int) {
+	for i := 0; i < n; i++ {
+		s.FailNext(path, http.StatusTooManyRequests)
+	}
+}
+
+// Requests returns how many requests path has received, including failed
+// ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// NotModified returns how many requests to path were answered with 304 Not
+// Modified.
+func (s *Server) NotModified(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified[path]
+}
+
+// middleware counts requests, checks the API key, and applies queued
+// failures before handing off to the endpoint.
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		var fail int
+		if q := s.failures[r.URL.Path]; len(q) > 0 {
+			fail, s.failures[r.URL.Path] = q[0], q[1:]
+		}
+		s.mu.Unlock()
+
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if key, _, ok := r.BasicAuth(); !ok || key != s.APIKey {
+			writeError(w, http.StatusUnauthorized, "invalid api key")
+			return
+		}
+		if fail != 0 {
+			if fail == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, fail, "rate limit exceeded")
+				return
+			}
+			writeError(w, fail, http.StatusText(fail))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON gzip-encodes every response, whatever the client asked for, as
+// the real API does.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, _ := json.Marshal(v)
+	writeBody(w, status, body)
+}
+
+func writeBody(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(status)
+	gz := gzip.NewWriter(w)
+	gz.Write(body)
+	gz.Close()
+}
+
+// writeMetadata writes a metadata response with an ETag derived from the
+// body and Last-Modified set to the server start. As with the real API, a
+// request whose If-None-Match matches the ETag, or whose If-Modified-Since
+// is not before Last-Modified, gets 304 Not Modified with no body.
+// If-None-Match takes precedence when both are sent.
+func (s *Server) writeMetadata(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, _ := json.Marshal(v)
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", s.modified.Format(http.TimeFormat))
+
+	notModified := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		notModified = etagMatch(inm, etag)
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		notModified = !s.modified.After(ims)
+	}
+	if notModified {
+		s.mu.Lock()
+		s.notModified[r.URL.Path]++
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeBody(w, http.StatusOK, body)
+}
+
+// etagMatch reports whether an If-None-Match header lists etag, or is "*".
+func etagMatch(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError writes an API error body. Errors are gzip-encoded too, which
+// is the quirk that clients reading error bodies have to handle.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{
+		"err_code": strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		"err_msg":  msg,
+	})
+}
+
+// idSet parses a comma-separated ID filter; nil matches everything.
+func idSet(r *http.Request, param string) map[string]bool {
+	v := r.URL.Query().Get(param)
+	if v == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, id := range strings.Split(v, ",") {
+		set[strings.TrimSpace(id)] = true
+	}
+	return set
+}
+
+func (s *Server) handleProject(w http.ResponseWriter, r *http.Request) {
+	b := s.Building
+	s.writeMetadata(w, r, map[string]interface{}{
+		"proj_id":   b.ProjID,
+		"proj_name": b.ProjName,
+		"city":      b.City,
+		"tz":        b.Tz,
+		"usage":     b.Usage,
+		"capacity":  b.Capacity,
+	})
+}
+
+func (s *Server) handleZones(w http.ResponseWriter, r *http.Request) {
+	ids := idSet(r, "zone_ids")
+	zones := []map[string]interface{}{}
+	for _, z := range s.Building.Zones {
+		if ids != nil && !ids[z.ID] {
+			continue
+		}
+		zones = append(zones, map[string]interface{}{
+			"id":               z.ID,
+			"name":             z.Name,
+			"type":             z.Type,
+			"fed_by_asset_ids": z.FedByAssetIDs,
+			"feeds_space_ids":  z.FeedsSpaceIDs,
+		})
+	}
+	s.writeMetadata(w, r, map[string]interface{}{"zones": zones})
+}
+
+func (s *Server) handleSpaces(w http.ResponseWriter, r *http.Request) {
+	ids := idSet(r, "space_ids")
+	spaces := []map[string]interface{}{}
+	for _, sp := range s.Building.Spaces {
+		if ids != nil && !ids[sp.ID] {
+			continue
+		}
+		spaces = append(spaces, map[string]interface{}{
+			"id":                 sp.ID,
+			"name":               sp.Name,
+			"type":               sp.Type,
+			"parent_space_id":    "",
+			"parent_zone_id":     sp.ParentZoneID,
+			"contains_asset_ids": sp.ContainsAssetIDs,
+			"props":              map[string]interface{}{"area": 250, "floor": "1"},
+		})
+	}
+	s.writeMetadata(w, r, map[string]interface{}{"spaces": spaces})
+}
+
+func (s *Server) handleAssets(w http.ResponseWriter, r *http.Request) {
+	ids := idSet(r, "asset_ids")
+	assets := []map[string]interface{}{}
+	for _, a := range s.Building.Assets {
+		if ids != nil && !ids[a.ID] {
+			continue
+		}
+		assets = append(assets, map[string]interface{}{
+			"id":         a.ID,
+			"name":       a.Name,
+			"type":       a.Type,
+			"props":      map[string]interface{}{"cfm_max": 1200, "install_year": "2019"},
+			"source_ids": a.SourceIDs,
+		})
+	}
+	s.writeMetadata(w, r, map[string]interface{}{"currency": "USD", "assets": assets})
+}
+
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	ids := idSet(r, "source_ids")
+	boundOnly := r.URL.Query().Get("bound_only") == "true"
+	sources := []map[string]interface{}{}
+	for _, src := range s.Building.Sources {
+		if ids != nil && !ids[src.ID] || boundOnly && !src.Bound {
+			continue
+		}
+		var deviceID interface{} = fmt.Sprint(src.DeviceID)
+		if src.NumericDeviceID {
+			deviceID = src.DeviceID
+		}
+		sources = append(sources, map[string]interface{}{
+			"id":              src.ID,
+			"name":            src.Name,
+			"type":            src.Type,
+			"addr":            src.Addr,
+			"device_id":       deviceID,
+			"vendor":          "Acme",
+			"model":           "VC-100",
+			"enabled":         src.Enabled,
+			"bound":           src.Bound,
+			"parent_asset_id": src.ParentAssetID,
+		})
+	}
+	s.writeMetadata(w, r, map[string]interface{}{"sources": sources})
+}
+
+// scopeSource resolves the source_id, asset_id, or space_id parameter of
+// /v1/points and /v1/values to a single source.
+func (s *Server) scopeSource(w http.ResponseWriter, r *http.Request) (*Source, bool) {
+	q := r.URL.Query()
+	id := q.Get("source_id")
+	switch {
+	case id != "":
+	case q.Get("asset_id") != "":
+		for _, a := range s.Building.Assets {
+			if a.ID == q.Get("asset_id") && len(a.SourceIDs) > 0 {
+				id = a.SourceIDs[0]
+			}
+		}
+	case q.Get("space_id") != "":
+		for _, a := range s.Building.Assets {
+			if a.SpaceID == q.Get("space_id") && len(a.SourceIDs) > 0 {
+				id = a.SourceIDs[0]
+				break
+			}
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "missing source_id, asset_id, or space_id")
+		return nil, false
+	}
+	src := s.Building.source(id)
+	if src == nil {
+		writeError(w, http.StatusNotFound, "source not found")
+		return nil, false
+	}
+	return src, true
+}
+
+// scopePoints filters a source's points by point_ids and point_types.
+func scopePoints(r *http.Request, src *Source) []Point {
+	ids := idSet(r, "point_ids")
+	types := idSet(r, "point_types")
+	var out []Point
+	for _, p := range src.Points {
+		if ids != nil && !ids[p.ID] || types != nil && !types[p.Type] {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (s *Server) handlePoints(w http.ResponseWriter, r *http.Request) {
+	src, ok := s.scopeSource(w, r)
+	if !ok {
+		return
+	}
+	points := []map[string]interface{}{}
+	for _, p := range scopePoints(r, src) {
+		points = append(points, map[string]interface{}{
+			"id":       p.ID,
+			"name":     p.Name,
+			"type":     p.Type,
+			"addr":     strings.TrimPrefix(p.ID, src.ID+"."),
+			"kind":     p.Kind,
+			"unit":     p.Unit,
+			"writable": p.Writable,
+		})
+	}
+	s.writeMetadata(w, r, map[string]interface{}{
+		"source_id":    src.ID,
+		"source_name":  src.Name,
+		"source_bound": src.Bound,
+		"points":       points,
+	})
+}
+
+func (s *Server) handleValues(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var src *Source
+	var points []Point
+	if q.Get("source_id") == "" && q.Get("asset_id") == "" && q.Get("space_id") == "" {
+		// point_ids alone may span sources. Values come back in request
+		// order, so tests see a stable order within a batch.
+		for _, id := range splitIDs(q.Get("point_ids")) {
+			if p, _ := s.Building.point(id); p != nil {
+				points = append(points, *p)
+			}
+		}
+	} else {
+		var ok bool
+		if src, ok = s.scopeSource(w, r); !ok {
+			return
+		}
+		points = scopePoints(r, src)
+	}
+
+	now := time.Now()
+	values := []map[string]interface{}{}
+	for _, p := range points {
+		v := p.Sample(now)
+		status := "ok"
+		if v == nil {
+			status = "unknown"
+		}
+		values = append(values, map[string]interface{}{"id": p.ID, "val": v, "status": status})
+	}
+	resp := map[string]interface{}{"values": values}
+	if src != nil {
+		resp["source_id"] = src.ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleTrends serves one row per interval step from the start of
+// start_date to the end of end_date in the building's time zone. As with
+// the real API each row holds "ts" plus one key per point ID, and points
+// without a sample are left out of the row rather than sent as null;
+// unknown point IDs are echoed in point_ids but never appear in a row.
+func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pointIDs := splitIDs(q.Get("point_ids"))
+	if len(pointIDs) == 0 {
+		writeError(w, http.StatusBadRequest, "missing point_ids")
+		return
+	}
+	loc, err := time.LoadLocation(s.Building.Tz)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	start, err1 := time.ParseInLocation("2006-01-02", q.Get("start_date"), loc)
+	end, err2 := time.ParseInLocation("2006-01-02", q.Get("end_date"), loc)
+	if err1 != nil || err2 != nil || end.Before(start) {
+		writeError(w, http.StatusBadRequest, "invalid start_date or end_date")
+		return
+	}
+	end = end.AddDate(0, 0, 1)
+
+	interval := q.Get("interval")
+	if interval == "" || interval == "auto" {
+		interval = autoInterval(end.Sub(start))
+	}
+	step, ok := intervalStep(interval)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid interval")
+		return
+	}
+	aggregate := q.Get("aggregate")
+	if aggregate == "" {
+		aggregate = "auto"
+	}
+
+	points := make([]*Point, len(pointIDs))
+	for i, id := range pointIDs {
+		points[i], _ = s.Building.point(id)
+	}
+	layout := time.RFC3339
+	if s.NaiveTimestamps {
+		layout = "2006-01-02T15:04:05"
+	}
+	rows := []map[string]interface{}{}
+	for t := start; t.Before(end); t = step(t) {
+		row := map[string]interface{}{"ts": t.Format(layout)}
+		for i, p := range points {
+			if p == nil {
+				continue
+			}
+			if v := p.Sample(t); v != nil {
+				row[pointIDs[i]] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"start":     start.Format(time.RFC3339),
+		"end":       end.Format(time.RFC3339),
+		"tz":        s.Building.Tz,
+		"interval":  interval,
+		"aggregate": aggregate,
+		"point_ids": pointIDs,
+		"trends":    rows,
+	})
+}
+
+func splitIDs(v string) []string {
+	var ids []string
+	for _, id := range strings.Split(v, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func autoInterval(span time.Duration) string {
+	switch {
+	case span <= 2*24*time.Hour:
+		return "5min"
+	case span <= 14*24*time.Hour:
+		return "15min"
+	case span <= 62*24*time.Hour:
+		return "1hr"
+	}
+	return "1day"
+}
+
+// intervalStep returns the function advancing a row timestamp by interval.
+// Day and month steps follow the calendar, so rows stay on local midnight
+// across DST changes.
+func intervalStep(interval string) (func(time.Time) time.Time, bool) {
+	fixed := map[string]time.Duration{
+		"raw":   5 * time.Minute,
+		"5min":  5 * time.Minute,
+		"15min": 15 * time.Minute,
+		"30min": 30 * time.Minute,
+		"1hr":   time.Hour,
+	}
+	if d, ok := fixed[interval]; ok {
+		return func(t time.Time) time.Time { return t.Add(d) }, true
+	}
+	switch interval {
+	case "1day":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, true
+	case "1mo":
+		return func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, true
+	}
+	return nil, false
+}
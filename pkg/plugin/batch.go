@@ -0,0 +1,254 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPointBatchSize keeps the point_ids query parameter well under
+// common URL length limits for typical Novant point IDs ("p123").
+const defaultPointBatchSize = 100
+
+func pointBatchSize(settings Settings) int {
+	if settings.PointBatchSize > 0 {
+		return settings.PointBatchSize
+	}
+	return defaultPointBatchSize
+}
+
+// splitPointIDs splits a comma-separated point ID list into batches of at
+// most size IDs, dropping blanks and duplicates but keeping the requested
+// order. It returns nil for an empty list.
+func splitPointIDs(ids string, size int) []string {
+	if ids == "" {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	var batches []string
+	var cur []string
+	for _, id := range strings.Split(ids, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		cur = append(cur, id)
+		if len(cur) == size {
+			batches = append(batches, strings.Join(cur, ","))
+			cur = cur[:0]
+		}
+	}
+	if len(cur) > 0 {
+		batches = append(batches, strings.Join(cur, ","))
+	}
+	return batches
+}
+
+// fetchBatches runs fetch for every batch concurrently and merges the
+// results in batch order. Concurrency is bounded by the client's limiter,
+// not here. The first failure cancels the remaining batches and is returned.
+func fetchBatches[T any](ctx context.Context, batches []string, fetch func(context.Context, string) (*T, error), merge func([]*T) *T) (*T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*T, len(batches))
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i, ids := range batches {
+		wg.Add(1)
+		go func(i int, ids string) {
+			defer wg.Done()
+			results[i], errs[i] = fetch(ctx, ids)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, ids)
+	}
+	wg.Wait()
+
+	// Report the error that caused the cancellation rather than the
+	// context errors it produced in the other batches.
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return merge(results), nil
+}
+
+// mergeValues concatenates batched /v1/values responses in request order.
+func mergeValues(parts []*ValuesResp) *ValuesResp {
+	merged := &ValuesResp{SourceID: parts[0].SourceID}
+	for _, p := range parts {
+		merged.Values = append(merged.Values, p.Values...)
+	}
+	return merged
+}
+
+// mergeTrends combines /v1/trends responses for the same request split by
+// point (batches) or by time (chunks). Batches normally share the same
+// timestamps, in which case the series are simply collected. Otherwise the
+// timelines are unioned in time order, rows repeated at chunk boundaries
+// are de-duplicated, and each series is re-aligned, with null samples where
+// no part had a row. Where parts overlap, the first non-null sample wins.
+// Naive timestamps are read in the parts' tz, or in loc, the project time
+// zone, when the response has none.
+func mergeTrends(parts []*TrendsResp, loc *time.Location) *TrendsResp {
+	first, last := parts[0], parts[len(parts)-1]
+	merged := &TrendsResp{
+		Start:     first.Start,
+		End:       last.End,
+		Tz:        first.Tz,
+		Interval:  first.Interval,
+		Aggregate: first.Aggregate,
+		Series:    make(map[string]*TrendSeries),
+	}
+	seenPoint := make(map[string]struct{})
+	for _, p := range parts {
+		for _, id := range p.PointIDs {
+			if _, ok := seenPoint[id]; !ok {
+				seenPoint[id] = struct{}{}
+				merged.PointIDs = append(merged.PointIDs, id)
+			}
+		}
+	}
+
+	if sameTimeline(parts) && disjointSeries(parts) {
+		merged.Ts = first.Ts
+		for _, p := range parts {
+			for id, s := range p.Series {
+				merged.Series[id] = s
+			}
+		}
+		return merged
+	}
+
+	// Rows are matched by instant, so boundary rows de-duplicate even when
+	// parts render them differently, and a wall-clock hour repeated at the
+	// end of DST stays two rows.
+	loc = trendLocation(first.Tz, loc)
+	type stamp struct {
+		ts     string
+		t      time.Time
+		parsed bool
+	}
+	stamps := make(map[string]stamp)
+	keys := make([][]string, len(parts))
+	for i, p := range parts {
+		clock := newTrendClock(loc)
+		keys[i] = make([]string, len(p.Ts))
+		for j, ts := range p.Ts {
+			key := "ts:" + ts
+			t, err := clock.parse(ts)
+			if err == nil {
+				key = strconv.FormatInt(t.UnixNano(), 10)
+			}
+			keys[i][j] = key
+			if _, ok := stamps[key]; !ok {
+				stamps[key] = stamp{ts: ts, t: t, parsed: err == nil}
+			}
+		}
+	}
+	order := make([]string, 0, len(stamps))
+	for key := range stamps {
+		order = append(order, key)
+	}
+	// Timestamps that do not parse sort lexically after those that do.
+	sort.Slice(order, func(i, j int) bool {
+		a, b := stamps[order[i]], stamps[order[j]]
+		switch {
+		case a.parsed && b.parsed:
+			return a.t.Before(b.t)
+		case a.parsed != b.parsed:
+			return a.parsed
+		}
+		return a.ts < b.ts
+	})
+	row := make(map[string]int, len(order))
+	for i, key := range order {
+		row[key] = i
+		merged.Ts = append(merged.Ts, stamps[key].ts)
+	}
+
+	for pi, p := range parts {
+		for id, s := range p.Series {
+			out, ok := merged.Series[id]
+			if !ok {
+				out = &TrendSeries{}
+				out.padTo(len(merged.Ts))
+				merged.Series[id] = out
+			}
+			for i, key := range keys[pi] {
+				r := row[key]
+				if out.Kinds[r] != SampleNull {
+					continue
+				}
+				v := s.Value(i)
+				if v == nil {
+					continue
+				}
+				out.Kinds[r] = s.Kinds[i]
+				if s.Kinds[i] == SampleNumber {
+					out.Nums[r] = s.Nums[i]
+				} else {
+					if out.Other == nil {
+						out.Other = make(map[int]interface{})
+					}
+					out.Other[r] = v
+				}
+			}
+		}
+	}
+	for _, id := range merged.PointIDs {
+		if _, ok := merged.Series[id]; !ok {
+			s := &TrendSeries{}
+			s.padTo(len(merged.Ts))
+			merged.Series[id] = s
+		}
+	}
+	return merged
+}
+
+// disjointSeries reports whether no point appears in more than one part.
+func disjointSeries(parts []*TrendsResp) bool {
+	seen := make(map[string]struct{})
+	for _, p := range parts {
+		for id := range p.Series {
+			if _, dup := seen[id]; dup {
+				return false
+			}
+			seen[id] = struct{}{}
+		}
+	}
+	return true
+}
+
+func sameTimeline(parts []*TrendsResp) bool {
+	ts := parts[0].Ts
+	for _, p := range parts[1:] {
+		if len(p.Ts) != len(ts) {
+			return false
+		}
+		for i := range ts {
+			if p.Ts[i] != ts[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
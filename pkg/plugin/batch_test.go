@@ -0,0 +1,213 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+func TestSplitPointIDs(t *testing.T) {
+	tests := []struct {
+		ids  string
+		size int
+		want []string
+	}{
+		{"", 2, nil},
+		{" , ,", 2, nil},
+		{"s.1.1", 2, []string{"s.1.1"}},
+		{"s.1.1,s.1.2", 2, []string{"s.1.1,s.1.2"}},
+		{"s.1.1,s.1.2,s.1.3", 2, []string{"s.1.1,s.1.2", "s.1.3"}},
+		{"s.1.3, s.1.1 ,,s.1.3,s.1.2", 2, []string{"s.1.3,s.1.1", "s.1.2"}},
+		{"s.1.1,s.1.2,s.1.3", 1, []string{"s.1.1", "s.1.2", "s.1.3"}},
+	}
+	for _, tt := range tests {
+		got := splitPointIDs(tt.ids, tt.size)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitPointIDs(%q, %d) = %q, want %q", tt.ids, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestFetchBatches(t *testing.T) {
+	batches := []string{"a", "b", "c"}
+	join := func(parts []*string) *string {
+		var out []string
+		for _, p := range parts {
+			out = append(out, *p)
+		}
+		s := strings.Join(out, ",")
+		return &s
+	}
+
+	t.Run("merged in batch order", func(t *testing.T) {
+		// Later batches finish first.
+		delay := map[string]time.Duration{"a": 20 * time.Millisecond, "b": 10 * time.Millisecond}
+		got, err := fetchBatches(context.Background(), batches, func(_ context.Context, ids string) (*string, error) {
+			time.Sleep(delay[ids])
+			return &ids, nil
+		}, join)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != "a,b,c" {
+			t.Errorf("merged %q, want a,b,c", *got)
+		}
+	})
+
+	t.Run("first failure cancels the rest", func(t *testing.T) {
+		boom := errors.New("boom")
+		_, err := fetchBatches(context.Background(), batches, func(ctx context.Context, ids string) (*string, error) {
+			if ids == "b" {
+				return nil, boom
+			}
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("batch %s: %w", ids, ctx.Err())
+			case <-time.After(time.Second):
+				return &ids, nil
+			}
+		}, join)
+		if !errors.Is(err, boom) {
+			t.Errorf("err = %v, want the failing batch's error rather than a cancellation", err)
+		}
+	})
+}
+
+func TestMergeValues(t *testing.T) {
+	merged := mergeValues([]*ValuesResp{
+		{SourceID: "s.1", Values: []PointValue{{ID: "s.1.1", Val: 1.0}, {ID: "s.1.2", Val: 2.0}}},
+		{SourceID: "s.1", Values: []PointValue{{ID: "s.1.3", Val: 3.0}}},
+	})
+	var ids []string
+	for _, v := range merged.Values {
+		ids = append(ids, v.ID)
+	}
+	if merged.SourceID != "s.1" || strings.Join(ids, ",") != "s.1.1,s.1.2,s.1.3" {
+		t.Errorf("merged %s %v, want s.1 [s.1.1 s.1.2 s.1.3]", merged.SourceID, ids)
+	}
+}
+
+func TestMergeTrends(t *testing.T) {
+	part := func(ts []string, series map[string][]interface{}) *TrendsResp {
+		p := &TrendsResp{Tz: "UTC", Interval: "1hr", Ts: ts, Series: map[string]*TrendSeries{}}
+		for id, vals := range series {
+			p.PointIDs = append(p.PointIDs, id)
+			s := &TrendSeries{}
+			for _, v := range vals {
+				s.append(v)
+			}
+			p.Series[id] = s
+		}
+		return p
+	}
+	h := func(hours ...int) []string {
+		var out []string
+		for _, n := range hours {
+			out = append(out, fmt.Sprintf("2026-01-01T%02d:00:00", n))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		parts []*TrendsResp
+		ts    []string
+		want  map[string][]interface{}
+	}{
+		{"batches on one timeline",
+			[]*TrendsResp{
+				part(h(0, 1), map[string][]interface{}{"s.1.1": {1.0, 2.0}}),
+				part(h(0, 1), map[string][]interface{}{"s.1.2": {true, nil}}),
+			},
+			h(0, 1),
+			map[string][]interface{}{"s.1.1": {1.0, 2.0}, "s.1.2": {true, nil}}},
+		{"batches with different rows",
+			[]*TrendsResp{
+				part(h(0, 2), map[string][]interface{}{"s.1.1": {1.0, 3.0}}),
+				part(h(1, 2), map[string][]interface{}{"s.1.2": {"on", "off"}}),
+			},
+			h(0, 1, 2),
+			map[string][]interface{}{"s.1.1": {1.0, nil, 3.0}, "s.1.2": {nil, "on", "off"}}},
+		{"chunks overlapping at the boundary",
+			[]*TrendsResp{
+				part(h(0, 1, 2), map[string][]interface{}{"s.1.1": {1.0, 2.0, nil}}),
+				part(h(2, 3), map[string][]interface{}{"s.1.1": {30.0, 4.0}}),
+			},
+			h(0, 1, 2, 3),
+			map[string][]interface{}{"s.1.1": {1.0, 2.0, 30.0, 4.0}}},
+		{"first non-null sample wins",
+			[]*TrendsResp{
+				part(h(0, 1), map[string][]interface{}{"s.1.1": {1.0, 2.0}}),
+				part(h(1, 2), map[string][]interface{}{"s.1.1": {20.0, 3.0}}),
+			},
+			h(0, 1, 2),
+			map[string][]interface{}{"s.1.1": {1.0, 2.0, 3.0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeTrends(tt.parts, time.UTC)
+			if strings.Join(merged.Ts, ",") != strings.Join(tt.ts, ",") {
+				t.Fatalf("ts = %v, want %v", merged.Ts, tt.ts)
+			}
+			for id, want := range tt.want {
+				s := merged.Series[id]
+				if s == nil || s.Len() != len(want) {
+					t.Fatalf("%s: series %v, want %d rows", id, s, len(want))
+				}
+				for i, w := range want {
+					if got := s.Value(i); got != w {
+						t.Errorf("%s[%d] = %v, want %v", id, i, got, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+// A point list longer than the batch size is fetched in batches and comes
+// back as one frame in the requested order.
+func TestQueryBatchedPoints(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, srv := newTestDatasource(t, b, map[string]interface{}{"pointBatchSize": 2})
+	ids := []string{"s.3.1", "s.1.2", "s.2.1", "s.1.1", "s.4.3"}
+
+	resp := runQuery(t, ds, "values", map[string]interface{}{"pointIds": strings.Join(ids, ",")}, time.Now().Add(-time.Hour), time.Now())
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if got := stringColumn(t, resp.Frames[0], "id"); strings.Join(got, ",") != strings.Join(ids, ",") {
+		t.Errorf("ids = %v, want %v", got, ids)
+	}
+	if n := srv.Requests("/v1/values"); n != 3 {
+		t.Errorf("%d /v1/values requests, want 3", n)
+	}
+
+	loc, _ := time.LoadLocation(b.Tz)
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	resp = runQuery(t, ds, "trends", map[string]interface{}{"pointIds": strings.Join(ids, ","), "interval": "1hr"}, day, day.Add(24*time.Hour-time.Second))
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	f := resp.Frames[0]
+	if len(f.Fields) != len(ids)+1 {
+		t.Fatalf("%d fields, want time plus %d points", len(f.Fields), len(ids))
+	}
+	if rows, _ := f.RowLen(); rows != 24 {
+		t.Errorf("%d rows, want 24", rows)
+	}
+	if n := srv.Requests("/v1/trends"); n != 3 {
+		t.Errorf("%d /v1/trends requests, want 3", n)
+	}
+
+	// A different list misses the value cache.
+	srv.FailNext("/v1/values", http.StatusBadRequest)
+	if resp := runQuery(t, ds, "values", map[string]interface{}{"pointIds": strings.Join(ids[1:], ",")}, time.Now().Add(-time.Hour), time.Now()); resp.Error == nil {
+		t.Error("a failed batch did not fail the query")
+	}
+}
@@ -1,16 +1,21 @@
 package plugin
 
 import (
+	"context"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // pointCacheTTL is how long cached point metadata is considered fresh.
-// Point names rarely change after commissioning, so a long TTL is fine; on
-// miss we re-fetch /v1/points. Users can also clear the cache manually via
-// the data source config UI.
-const pointCacheTTL = 24 * time.Hour
+// Point names rarely change after commissioning; on miss we revalidate
+// /v1/points with a conditional GET, which costs a 304 when nothing changed,
+// so the TTL can stay short enough that renames show up within the hour.
+// Users can also clear the cache manually via the data source config UI.
+const pointCacheTTL = time.Hour
 
 // valueCacheTTL is how long a /v1/values response is considered fresh.
 // The Novant API publishes new values every ~30 seconds, so caching for
@@ -28,10 +33,14 @@ type sourceEntry struct {
 type pointCache struct {
 	mu      sync.RWMutex
 	sources map[string]*sourceEntry
+	flights *coalescer[*PointsResp]
 }
 
 func newPointCache() *pointCache {
-	return &pointCache{sources: make(map[string]*sourceEntry)}
+	return &pointCache{
+		sources: make(map[string]*sourceEntry),
+		flights: newCoalescer[*PointsResp]("points"),
+	}
 }
 
 // extractSourceID returns the "s.<n>" prefix of a point ID. Point IDs follow
@@ -46,19 +55,26 @@ func extractSourceID(pointID string) string {
 }
 
 // ensureSource fetches and caches the points for a source if not yet cached or stale.
-// On API errors, an existing (stale) cache entry is left in place; otherwise the
-// miss is silent and lookups will fall back to the raw point ID.
-func (c *pointCache) ensureSource(client *Client, sourceID string) {
+// Concurrent misses for the same source share one /v1/points call. On API errors
+// (including ctx cancellation), an existing (stale) cache entry is left in place;
+// otherwise the miss is silent and lookups will fall back to the raw point ID.
+func (c *pointCache) ensureSource(ctx context.Context, client *Client, sourceID string) {
 	c.mu.RLock()
 	entry, ok := c.sources[sourceID]
 	c.mu.RUnlock()
 
 	if ok && time.Since(entry.fetched) < pointCacheTTL {
+		recordCacheLookup("points", true)
 		return
 	}
+	recordCacheLookup("points", false)
+	loggerFromContext(ctx).Debug("Point cache miss", "sourceId", sourceID, "stale", ok)
 
-	resp, err := client.GetPoints(sourceID, "", "", "", "")
+	resp, err := c.flights.do(ctx, sourceID, func(ctx context.Context) (*PointsResp, error) {
+		return client.GetPoints(ctx, sourceID, "", "", "", "")
+	})
 	if err != nil {
+		loggerFromContext(ctx).Warn("Point metadata fetch failed; using raw point IDs", "sourceId", sourceID, "error", redact(err.Error()))
 		return
 	}
 
@@ -68,6 +84,9 @@ func (c *pointCache) ensureSource(client *Client, sourceID string) {
 	}
 
 	c.mu.Lock()
+	if _, replaced := c.sources[sourceID]; replaced {
+		cacheEvictions.WithLabelValues("points").Inc()
+	}
 	c.sources[sourceID] = &sourceEntry{fetched: time.Now(), points: points}
 	c.mu.Unlock()
 }
@@ -77,6 +96,7 @@ func (c *pointCache) ensureSource(client *Client, sourceID string) {
 func (c *pointCache) clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	cacheEvictions.WithLabelValues("points").Add(float64(len(c.sources)))
 	c.sources = make(map[string]*sourceEntry)
 }
 
@@ -91,47 +111,111 @@ type valuesEntry struct {
 type valueCache struct {
 	mu      sync.RWMutex
 	entries map[string]*valuesEntry
+	flights *coalescer[*ValuesResp]
 }
 
 func newValueCache() *valueCache {
-	return &valueCache{entries: make(map[string]*valuesEntry)}
+	return &valueCache{
+		entries: make(map[string]*valuesEntry),
+		flights: newCoalescer[*ValuesResp]("values"),
+	}
 }
 
+// valueCacheKey builds the cache and coalescing key for a /v1/values request.
+// ID lists are normalized (trimmed, de-duplicated, sorted) so panels asking
+// for the same points in a different order share one entry.
 func valueCacheKey(sourceID, assetID, spaceID, pointIDs, pointTypes string) string {
-	return strings.Join([]string{sourceID, assetID, spaceID, pointIDs, pointTypes}, "|")
+	return strings.Join([]string{
+		strings.TrimSpace(sourceID),
+		strings.TrimSpace(assetID),
+		strings.TrimSpace(spaceID),
+		normalizeIDList(pointIDs),
+		normalizeIDList(pointTypes),
+	}, "|")
+}
+
+// normalizeIDList canonicalizes a comma-separated ID list.
+func normalizeIDList(ids string) string {
+	if ids == "" {
+		return ""
+	}
+	parts := strings.Split(ids, ",")
+	seen := make(map[string]struct{}, len(parts))
+	out := parts[:0]
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, dup := seen[p]; dup {
+			continue
+		}
+		seen[p] = struct{}{}
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
 }
 
-// getOrFetch returns the cached response if fresh, otherwise calls fetch and
-// stores the result. Errors from fetch are returned without caching.
-func (c *valueCache) getOrFetch(key string, fetch func() (*ValuesResp, error)) (*ValuesResp, error) {
+// getOrFetch returns the cached response if fresh, otherwise calls fetch with
+// ctx and stores the result. Concurrent misses for the same key share one
+// fetch. Errors from fetch are returned without caching.
+func (c *valueCache) getOrFetch(ctx context.Context, key string, fetch func(context.Context) (*ValuesResp, error)) (*ValuesResp, error) {
+	ctx, span := startSpan(ctx, "novant.cache.values")
+	defer span.End()
+
 	c.mu.RLock()
 	entry, ok := c.entries[key]
 	c.mu.RUnlock()
 	if ok && time.Since(entry.fetched) < valueCacheTTL {
+		recordCacheLookup("values", true)
+		span.SetAttributes(attribute.Bool("novant.cache_hit", true))
+		loggerFromContext(ctx).Debug("Value cache hit", "key", redact(key))
 		return entry.resp, nil
 	}
+	recordCacheLookup("values", false)
+	span.SetAttributes(attribute.Bool("novant.cache_hit", false))
+	loggerFromContext(ctx).Debug("Value cache miss", "key", redact(key))
 
-	resp, err := fetch()
-	if err != nil {
-		return nil, err
-	}
+	return c.flights.do(ctx, key, func(ctx context.Context) (*ValuesResp, error) {
+		resp, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.evictExpired()
+		c.entries[key] = &valuesEntry{fetched: time.Now(), resp: resp}
+		c.mu.Unlock()
+		return resp, nil
+	})
+}
 
-	c.mu.Lock()
-	c.entries[key] = &valuesEntry{fetched: time.Now(), resp: resp}
-	c.mu.Unlock()
-	return resp, nil
+// evictExpired drops stale entries so one-off queries don't accumulate.
+// Callers must hold c.mu.
+func (c *valueCache) evictExpired() {
+	for k, e := range c.entries {
+		if time.Since(e.fetched) >= valueCacheTTL {
+			delete(c.entries, k)
+			cacheEvictions.WithLabelValues("values").Inc()
+		}
+	}
 }
 
 // clear removes all cached entries.
 func (c *valueCache) clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	cacheEvictions.WithLabelValues("values").Add(float64(len(c.entries)))
 	c.entries = make(map[string]*valuesEntry)
 }
 
-// resolveNames returns a map of pointID → display name for the given point IDs.
-// Falls back to the point ID itself if no cached name is available.
-func (c *pointCache) resolveNames(client *Client, pointIDs []string) map[string]string {
+// resolvePoints returns the cached metadata of the given point IDs, fetching
+// each source's points at most once. Points that cannot be resolved are
+// absent from the map.
+func (c *pointCache) resolvePoints(ctx context.Context, client *Client, pointIDs []string) map[string]Point {
+	ctx, span := startSpan(ctx, "novant.cache.resolvePoints", attribute.Int("novant.points", len(pointIDs)))
+	defer span.End()
+
 	// Collect unique source IDs so we fetch each source's points only once.
 	sources := make(map[string]struct{})
 	for _, pid := range pointIDs {
@@ -139,21 +223,42 @@ func (c *pointCache) resolveNames(client *Client, pointIDs []string) map[string]
 			sources[sid] = struct{}{}
 		}
 	}
+	span.SetAttributes(attribute.Int("novant.sources", len(sources)))
 	for sid := range sources {
-		c.ensureSource(client, sid)
+		if ctx.Err() != nil {
+			break
+		}
+		c.ensureSource(ctx, client, sid)
 	}
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	points := make(map[string]Point, len(pointIDs))
+	for _, pid := range pointIDs {
+		if entry, ok := c.sources[extractSourceID(pid)]; ok {
+			if p, found := entry.points[pid]; found {
+				points[pid] = p
+			}
+		}
+	}
+	return points
+}
+
+// resolveNames returns a map of pointID → display name for the given point IDs.
+// Falls back to the point ID itself if no cached name is available.
+func (c *pointCache) resolveNames(ctx context.Context, client *Client, pointIDs []string) map[string]string {
+	points := c.resolvePoints(ctx, client, pointIDs)
+	return pointNames(pointIDs, points)
+}
+
+// pointNames maps each point ID to its display name, or to itself if the
+// point has no known name.
+func pointNames(pointIDs []string, points map[string]Point) map[string]string {
 	names := make(map[string]string, len(pointIDs))
 	for _, pid := range pointIDs {
 		names[pid] = pid // default fallback
-		if sid := extractSourceID(pid); sid != "" {
-			if entry, ok := c.sources[sid]; ok {
-				if p, found := entry.points[pid]; found && p.Name != "" {
-					names[pid] = p.Name
-				}
-			}
+		if p, ok := points[pid]; ok && p.Name != "" {
+			names[pid] = p.Name
 		}
 	}
 	return names
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// maxTrendCellsPerRequest caps rows × points per /v1/trends request. Longer
+// ranges are split into day-aligned chunks fetched concurrently, which
+// keeps each response small enough to come back well inside the timeout.
+const maxTrendCellsPerRequest = 250000
+
+// maxTrendMonthsPerRequest caps the span of a monthly /v1/trends request.
+// A month row is cheap to return but aggregates a whole month of samples on
+// the server, so the cell cap alone would let one request cover centuries
+// at the default batch size.
+const maxTrendMonthsPerRequest = 12
+
+// trendChunk is an inclusive range of project-local days.
+type trendChunk struct {
+	start, end time.Time
+}
+
+func (c trendChunk) startDate() string { return c.start.Format("2006-01-02") }
+func (c trendChunk) endDate() string   { return c.end.Format("2006-01-02") }
+
+// trendChunks splits the days from start to end (local midnights) into
+// chunks sized so that interval rows × points stays under
+// maxTrendCellsPerRequest. Days are stepped with AddDate, so chunks stay on
+// local midnight across DST changes. Monthly chunks break on month starts
+// (see monthChunks). Intervals of unknown spacing are not split.
+func trendChunks(start, end time.Time, interval string, points int) []trendChunk {
+	step, ok := intervalStep(interval)
+	if interval == "raw" {
+		step, ok = rawStepEstimate, true
+	}
+	if !ok || points <= 0 {
+		return []trendChunk{{start, end}}
+	}
+	if interval == "1mo" {
+		return monthChunks(start, end, points)
+	}
+
+	rowsPerDay := int((24 * time.Hour) / step)
+	if rowsPerDay < 1 {
+		rowsPerDay = 1
+	}
+	days := maxTrendCellsPerRequest / (rowsPerDay * points)
+	if days < 1 {
+		days = 1
+	}
+
+	var chunks []trendChunk
+	for d := start; !d.After(end); d = d.AddDate(0, 0, days) {
+		last := d.AddDate(0, 0, days-1)
+		if last.After(end) {
+			last = end
+		}
+		chunks = append(chunks, trendChunk{d, last})
+	}
+	return chunks
+}
+
+// monthChunks splits the days from start to end into chunks of whole
+// calendar months, at most maxTrendMonthsPerRequest each. A month row aggregates the days requested within it, so
+// a month split across two chunks would come back as two partial aggregates
+// that mergeTrends cannot combine; every chunk but the first therefore
+// starts on the 1st, and every chunk but the last ends on a month's last
+// day.
+func monthChunks(start, end time.Time, points int) []trendChunk {
+	months := maxTrendCellsPerRequest / points
+	if months > maxTrendMonthsPerRequest {
+		months = maxTrendMonthsPerRequest
+	}
+	if months < 1 {
+		months = 1
+	}
+
+	var chunks []trendChunk
+	for d := start; !d.After(end); {
+		next := time.Date(d.Year(), d.Month()+time.Month(months), 1, 0, 0, 0, 0, d.Location())
+		last := next.AddDate(0, 0, -1)
+		if last.After(end) {
+			last = end
+		}
+		chunks = append(chunks, trendChunk{d, last})
+		d = next
+	}
+	return chunks
+}
+
+// pointsPerRequest returns the most point IDs a single /v1/trends request
+// will carry once pointIDs is split into batches.
+func pointsPerRequest(pointIDs string, batchSize int) int {
+	batches := splitPointIDs(pointIDs, batchSize)
+	switch len(batches) {
+	case 0:
+		return 0
+	case 1:
+		return strings.Count(batches[0], ",") + 1
+	}
+	return batchSize
+}
+
+// fetchTrends fetches trends for the local days startDay to endDay, in
+// chunks if the range is long. The days are midnights in the project time
+// zone, which is also used to line up chunks and batches. failed lists the
+// chunks left out of a partial response (see getTrendsChunked).
+func (p *project) fetchTrends(ctx context.Context, pointIDs string, startDay, endDay time.Time, interval, aggregate string) (resp *TrendsResp, notices []data.Notice, failed []trendChunk, err error) {
+	loc := startDay.Location()
+	chunks := trendChunks(startDay, endDay, interval, pointsPerRequest(pointIDs, p.client.batchSize))
+	if len(chunks) > 1 {
+		return p.getTrendsChunked(ctx, pointIDs, chunks, interval, aggregate, loc)
+	}
+	c := chunks[0]
+	resp, err = p.client.GetTrends(ctx, pointIDs, c.startDate(), c.endDate(), interval, aggregate, loc)
+	return resp, nil, nil, err
+}
+
+// getTrendsChunked fetches every chunk concurrently through GetTrends and
+// stitches the results onto one timeline. Chunks that fail are left out,
+// returned in failed, and reported as warning notices, so a long-range
+// panel still shows the data that could be fetched; only if every chunk
+// fails is an error returned. loc is the project time zone.
+func (p *project) getTrendsChunked(ctx context.Context, pointIDs string, chunks []trendChunk, interval, aggregate string, loc *time.Location) (*TrendsResp, []data.Notice, []trendChunk, error) {
+	results := make([]*TrendsResp, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i, c := range chunks {
+		wg.Add(1)
+		go func(i int, c trendChunk) {
+			defer wg.Done()
+			results[i], errs[i] = p.client.GetTrends(ctx, pointIDs, c.startDate(), c.endDate(), interval, aggregate, loc)
+		}(i, c)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var parts []*TrendsResp
+	var failed []string
+	var failedChunks []trendChunk
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s to %s: %v", chunks[i].startDate(), chunks[i].endDate(), err))
+			failedChunks = append(failedChunks, chunks[i])
+			if firstErr == nil || errors.Is(firstErr, context.Canceled) {
+				firstErr = err
+			}
+			continue
+		}
+		parts = append(parts, results[i])
+	}
+	if len(parts) == 0 {
+		return nil, nil, nil, firstErr
+	}
+
+	var notices []data.Notice
+	if len(failed) > 0 {
+		loggerFromContext(ctx).Warn("Some trend chunks failed", "failed", len(failed), "chunks", len(chunks))
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text: fmt.Sprintf("Partial data: %d of %d time ranges could not be fetched (%s)",
+				len(failed), len(chunks), strings.Join(failed, "; ")),
+		})
+	}
+	return mergeTrends(parts, loc), notices, failedChunks, nil
+}
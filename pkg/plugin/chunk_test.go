@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+func TestTrendChunks(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, loc) }
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		interval   string
+		points     int
+		want       string
+	}{
+		{"fits in one request", day(2026, 3, 1), day(2026, 3, 31), "1hr", 10, "2026-03-01..2026-03-31"},
+		{"unknown interval", day(2026, 1, 1), day(2026, 12, 31), "bogus", 100, "2026-01-01..2026-12-31"},
+		// 250000 / (96 rows × 100 points) = 26 days per chunk.
+		{"15min across spring DST", day(2026, 3, 1), day(2026, 4, 30), "15min", 100, "2026-03-01..2026-03-26 2026-03-27..2026-04-21 2026-04-22..2026-04-30"},
+		// 250000 / (1440 rows × 100 points) = 1 day per chunk.
+		{"raw across fall DST", day(2026, 10, 31), day(2026, 11, 2), "raw", 100, "2026-10-31..2026-10-31 2026-11-01..2026-11-01 2026-11-02..2026-11-02"},
+		// Monthly rows never reach the cell cap at the default batch size,
+		// so chunks are capped at a year, each later one starting on the 1st.
+		{"months capped at a year", day(2024, 11, 15), day(2027, 2, 10), "1mo", 100, "2024-11-15..2025-10-31 2025-11-01..2026-10-31 2026-11-01..2027-02-10"},
+		// A batch size raised to 25000: 250000 / 25000 = 10 months per chunk.
+		{"months bound by cells", day(2026, 1, 20), day(2027, 3, 5), "1mo", 25000, "2026-01-20..2026-10-31 2026-11-01..2027-03-05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range trendChunks(tt.start, tt.end, tt.interval, tt.points) {
+				got = append(got, c.startDate()+".."+c.endDate())
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("chunks = %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestPointsPerRequest(t *testing.T) {
+	tests := []struct {
+		ids       string
+		batchSize int
+		want      int
+	}{
+		{"", 100, 0},
+		{"s.1.1", 100, 1},
+		{"s.1.1,s.1.2,s.1.1", 100, 2},
+		{"s.1.1,s.1.2,s.1.3", 2, 2},
+	}
+	for _, tt := range tests {
+		if got := pointsPerRequest(tt.ids, tt.batchSize); got != tt.want {
+			t.Errorf("pointsPerRequest(%q, %d) = %d, want %d", tt.ids, tt.batchSize, got, tt.want)
+		}
+	}
+}
+
+// Three years of monthly trends are fetched a year per request and come
+// back as one row per month.
+func TestQueryMonthlyTrendsChunked(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, srv := newTestDatasource(t, b, nil)
+	loc, _ := time.LoadLocation(b.Tz)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
+	to := time.Date(2026, 12, 31, 23, 0, 0, 0, loc)
+	resp := runQuery(t, ds, "trends", map[string]interface{}{"pointIds": "s.1.1", "interval": "1mo"}, from, to)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := srv.Requests("/v1/trends"); n != 3 {
+		t.Errorf("%d /v1/trends requests, want 3", n)
+	}
+	times := resp.Frames[0].Fields[0]
+	if times.Len() != 36 {
+		t.Fatalf("%d rows, want 36", times.Len())
+	}
+	for i := 0; i < times.Len(); i++ {
+		if want := from.AddDate(0, i, 0); !times.At(i).(time.Time).Equal(want) {
+			t.Errorf("row %d at %v, want %v", i, times.At(i), want)
+		}
+	}
+}
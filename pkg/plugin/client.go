@@ -1,56 +1,189 @@
 package plugin
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const apiBaseURL = "https://api.novant.io"
+// streamDecoder is implemented by results that decode themselves from the
+// response stream rather than through json.Decoder.Decode.
+type streamDecoder interface {
+	decodeJSON(dec *json.Decoder) error
+}
 
 // Client is an HTTP client for the Novant API.
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
+	retry      retryPolicy
+	limiter    *apiLimiter
+	validators *validatorCache
+	batchSize  int // max point IDs per /v1/values or /v1/trends request
+	// project names the data source project the client serves, so
+	// record/replay keeps each project's fixtures apart.
+	project string
 }
 
-// NewClient creates a new Novant API client.
-func NewClient(apiKey string) *Client {
+// NewClient creates a new Novant API client. settings should come from
+// loadSettings; zero values select the plugin defaults. httpClient is
+// normally built by newHTTPClient; nil selects a plain client with the SDK's
+// default timeout.
+func NewClient(apiKey string, settings Settings, httpClient *http.Client) *Client {
+	baseURL := settings.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: httpclient.DefaultTimeoutOptions.Timeout}
+	}
 	return &Client{
 		apiKey:     apiKey,
-		httpClient: &http.Client{},
+		baseURL:    baseURL,
+		httpClient: httpClient,
+		retry:      newRetryPolicy(settings),
+		limiter:    sharedLimiter(apiKey, settings),
+		validators: newValidatorCache(),
+		batchSize:  pointBatchSize(settings),
 	}
 }
 
+// ClearValidators drops the bodies kept for conditional GETs, forcing the
+// next metadata request to download a full response.
+func (c *Client) ClearValidators() {
+	c.validators.clear()
+}
+
+// Close releases idle connections held by the underlying HTTP client.
+func (c *Client) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// BaseURL returns the Novant API endpoint this client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
 // get performs a GET request with the params encoded as a query string and returns the decoded response.
-func (c *Client) get(path string, params url.Values, result interface{}) error {
-	url := apiBaseURL + path
+// The request is bound to ctx, so a cancelled query aborts the HTTP call. Transient failures are
+// retried according to the client's retryPolicy.
+func (c *Client) get(ctx context.Context, path string, params url.Values, result interface{}) error {
+	url := c.baseURL + path
 	if params != nil && len(params) > 0 {
 		url += "?" + params.Encode()
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	ctx, span := startSpan(ctx, "novant.api "+path,
+		attribute.String("http.request.method", http.MethodGet),
+		attribute.String("url.path", path),
+		attribute.String("server.address", c.baseURL),
+	)
+	start := time.Now()
+	attempts := 0
+	var info callInfo
+	err := c.retry.do(ctx, path, func() error {
+		attempts++
+		info = callInfo{}
+		return c.getOnce(ctx, path, url, result, &info)
+	})
+	span.SetAttributes(attribute.Int("novant.attempts", attempts))
+	endSpan(span, err)
+
+	logArgs := []interface{}{
+		"endpoint", path,
+		"params", redactParams(params),
+		"status", info.status,
+		"duration", time.Since(start),
+		"attempts", attempts,
+		"bytes", info.bytes,
+		"revalidated", info.notModified,
+	}
+	if err != nil {
+		loggerFromContext(ctx).Warn("Novant API call failed", append(logArgs, "error", redact(err.Error()))...)
+	} else {
+		loggerFromContext(ctx).Debug("Novant API call", logArgs...)
+	}
+	return err
+}
+
+// callInfo records the outcome of one getOnce attempt for logging.
+type callInfo struct {
+	status      int
+	bytes       int64
+	notModified bool
+}
+
+// getOnce performs a single GET attempt against url. Each attempt waits its
+// turn on the shared per-key limiter; the wait is recorded on the query stats.
+// Non-200 responses are returned as *APIError. Requests to conditionalPaths
+// are revalidated with the stored ETag / Last-Modified, and a 304 response
+// is decoded from the stored body.
+func (c *Client) getOnce(ctx context.Context, path, url string, result interface{}, info *callInfo) error {
+	wait, err := c.limiter.acquire(ctx)
+	statsFromContext(ctx).addLimiterWait(wait)
+	rateLimitWait.Observe(wait.Seconds())
+	if err != nil {
+		return fmt.Errorf("waiting for rate limiter: %w", err)
+	}
+	defer c.limiter.release()
+
+	req, err := http.NewRequestWithContext(withFixtureProject(ctx, c.project), http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 
 	req.SetBasicAuth(c.apiKey, "")
 	req.Header.Set("Accept-Encoding", "gzip")
+	if id := correlationIDFromContext(ctx); id != "" {
+		req.Header.Set("X-Request-Id", id)
+	}
 
+	conditional := conditionalPaths[path]
+	var cached *validatedBody
+	if conditional {
+		if cached = c.validators.get(url); cached != nil {
+			setConditionalHeaders(req, cached)
+		}
+	}
+
+	sent := time.Now()
+	defer func() { statsFromContext(ctx).setRoundTrip(time.Since(sent)) }()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("executing request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	info.status = resp.StatusCode
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	body := &countingReader{r: resp.Body}
+	defer func() {
+		info.bytes = body.n
+		span.SetAttributes(attribute.Int64("http.response.body.size", body.n))
+	}()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		info.notModified = true
+		return decodeResult(bytes.NewReader(cached.body), result)
+	}
+
 	// The API gzip-compresses every response, including 4xx errors, so we
 	// have to wrap with gzip.Reader before reading either path.
-	var reader io.Reader = resp.Body
+	var reader io.Reader = body
 	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gr, err := gzip.NewReader(resp.Body)
+		gr, err := gzip.NewReader(body)
 		if err != nil {
 			return fmt.Errorf("creating gzip reader: %w", err)
 		}
@@ -60,61 +193,100 @@ func (c *Client) get(path string, params url.Values, result interface{}) error {
 
 	if resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(reader)
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(b))
+		apiErr := newAPIError(resp.StatusCode, path, b)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return apiErr
 	}
 
-	if err := json.NewDecoder(reader).Decode(result); err != nil {
-		return fmt.Errorf("decoding response: %w", err)
+	if conditional {
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("reading response: %w", err)
+		}
+		c.validators.put(url, resp.Header, b)
+		reader = bytes.NewReader(b)
 	}
 
+	_, decodeSpan := startSpan(ctx, "novant.decode", attribute.String("url.path", path))
+	err = decodeResult(reader, result)
+	endSpan(decodeSpan, err)
+	return err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeResult decodes a JSON body into result.
+func decodeResult(r io.Reader, result interface{}) error {
+	var err error
+	dec := json.NewDecoder(r)
+	if sd, ok := result.(streamDecoder); ok {
+		err = sd.decodeJSON(dec)
+	} else {
+		err = dec.Decode(result)
+	}
+	if err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
 	return nil
 }
 
-func (c *Client) GetProject() (*ProjectResp, error) {
+func (c *Client) GetProject(ctx context.Context) (*ProjectResp, error) {
 	var resp ProjectResp
-	if err := c.get("/v1/project", nil, &resp); err != nil {
+	if err := c.get(ctx, "/v1/project", nil, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetZones(zoneIDs string) (*ZonesResp, error) {
+func (c *Client) GetZones(ctx context.Context, zoneIDs string) (*ZonesResp, error) {
 	params := url.Values{}
 	if zoneIDs != "" {
 		params.Set("zone_ids", zoneIDs)
 	}
 	var resp ZonesResp
-	if err := c.get("/v1/zones", params, &resp); err != nil {
+	if err := c.get(ctx, "/v1/zones", params, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetSpaces(spaceIDs string) (*SpacesResp, error) {
+func (c *Client) GetSpaces(ctx context.Context, spaceIDs string) (*SpacesResp, error) {
 	params := url.Values{}
 	if spaceIDs != "" {
 		params.Set("space_ids", spaceIDs)
 	}
 	var resp SpacesResp
-	if err := c.get("/v1/spaces", params, &resp); err != nil {
+	if err := c.get(ctx, "/v1/spaces", params, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetAssets(assetIDs string) (*AssetsResp, error) {
+func (c *Client) GetAssets(ctx context.Context, assetIDs string) (*AssetsResp, error) {
 	params := url.Values{}
 	if assetIDs != "" {
 		params.Set("asset_ids", assetIDs)
 	}
 	var resp AssetsResp
-	if err := c.get("/v1/assets", params, &resp); err != nil {
+	if err := c.get(ctx, "/v1/assets", params, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetSources(sourceIDs string, boundOnly bool) (*SourcesResp, error) {
+func (c *Client) GetSources(ctx context.Context, sourceIDs string, boundOnly bool) (*SourcesResp, error) {
 	params := url.Values{}
 	if sourceIDs != "" {
 		params.Set("source_ids", sourceIDs)
@@ -123,13 +295,13 @@ func (c *Client) GetSources(sourceIDs string, boundOnly bool) (*SourcesResp, err
 		params.Set("bound_only", "true")
 	}
 	var resp SourcesResp
-	if err := c.get("/v1/sources", params, &resp); err != nil {
+	if err := c.get(ctx, "/v1/sources", params, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetPoints(sourceID, assetID, spaceID, pointIDs, pointTypes string) (*PointsResp, error) {
+func (c *Client) GetPoints(ctx context.Context, sourceID, assetID, spaceID, pointIDs, pointTypes string) (*PointsResp, error) {
 	params := url.Values{}
 	if sourceID != "" {
 		params.Set("source_id", sourceID)
@@ -145,13 +317,25 @@ func (c *Client) GetPoints(sourceID, assetID, spaceID, pointIDs, pointTypes stri
 		params.Set("point_types", pointTypes)
 	}
 	var resp PointsResp
-	if err := c.get("/v1/points", params, &resp); err != nil {
+	if err := c.get(ctx, "/v1/points", params, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetValues(sourceID, assetID, spaceID, pointIDs, pointTypes string) (*ValuesResp, error) {
+// GetValues fetches current values. Long point ID lists are split into
+// batches fetched concurrently and merged; see batch.go.
+func (c *Client) GetValues(ctx context.Context, sourceID, assetID, spaceID, pointIDs, pointTypes string) (*ValuesResp, error) {
+	batches := splitPointIDs(pointIDs, c.batchSize)
+	if len(batches) <= 1 {
+		return c.getValues(ctx, sourceID, assetID, spaceID, pointIDs, pointTypes)
+	}
+	return fetchBatches(ctx, batches, func(ctx context.Context, ids string) (*ValuesResp, error) {
+		return c.getValues(ctx, sourceID, assetID, spaceID, ids, pointTypes)
+	}, mergeValues)
+}
+
+func (c *Client) getValues(ctx context.Context, sourceID, assetID, spaceID, pointIDs, pointTypes string) (*ValuesResp, error) {
 	params := url.Values{}
 	if sourceID != "" {
 		params.Set("source_id", sourceID)
@@ -167,13 +351,29 @@ func (c *Client) GetValues(sourceID, assetID, spaceID, pointIDs, pointTypes stri
 		params.Set("point_types", pointTypes)
 	}
 	var resp ValuesResp
-	if err := c.get("/v1/values", params, &resp); err != nil {
+	if err := c.get(ctx, "/v1/values", params, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-func (c *Client) GetTrends(pointIDs, startDate, endDate, interval, aggregate string) (*TrendsResp, error) {
+// GetTrends fetches trend samples. Long point ID lists are split into
+// batches fetched concurrently and merged onto one timeline; see batch.go.
+// loc is the project time zone, used to line up batches whose responses
+// carry no tz.
+func (c *Client) GetTrends(ctx context.Context, pointIDs, startDate, endDate, interval, aggregate string, loc *time.Location) (*TrendsResp, error) {
+	batches := splitPointIDs(pointIDs, c.batchSize)
+	if len(batches) <= 1 {
+		return c.getTrends(ctx, pointIDs, startDate, endDate, interval, aggregate)
+	}
+	return fetchBatches(ctx, batches, func(ctx context.Context, ids string) (*TrendsResp, error) {
+		return c.getTrends(ctx, ids, startDate, endDate, interval, aggregate)
+	}, func(parts []*TrendsResp) *TrendsResp {
+		return mergeTrends(parts, loc)
+	})
+}
+
+func (c *Client) getTrends(ctx context.Context, pointIDs, startDate, endDate, interval, aggregate string) (*TrendsResp, error) {
 	params := url.Values{}
 	params.Set("point_ids", pointIDs)
 	params.Set("start_date", startDate)
@@ -185,48 +385,9 @@ func (c *Client) GetTrends(pointIDs, startDate, endDate, interval, aggregate str
 		params.Set("aggregate", aggregate)
 	}
 
-	// The trends response has dynamic keys per point ID in each trend row,
-	// so we decode to a raw structure first.
-	var raw json.RawMessage
-	if err := c.get("/v1/trends", params, &raw); err != nil {
-		return nil, err
-	}
-
-	// Decode known fields
 	var resp TrendsResp
-	if err := json.Unmarshal(raw, &resp); err != nil {
-		return nil, fmt.Errorf("decoding trends metadata: %w", err)
-	}
-
-	// Decode the trends array with dynamic point value keys
-	var rawOuter map[string]json.RawMessage
-	if err := json.Unmarshal(raw, &rawOuter); err != nil {
-		return nil, fmt.Errorf("decoding trends raw: %w", err)
-	}
-
-	trendsRaw, ok := rawOuter["trends"]
-	if !ok {
-		return &resp, nil
-	}
-
-	var rawTrends []map[string]interface{}
-	if err := json.Unmarshal(trendsRaw, &rawTrends); err != nil {
-		return nil, fmt.Errorf("decoding trend rows: %w", err)
-	}
-
-	resp.Trends = make([]TrendRow, len(rawTrends))
-	for i, row := range rawTrends {
-		ts, _ := row["ts"].(string)
-		resp.Trends[i] = TrendRow{
-			Ts:     ts,
-			Values: make(map[string]interface{}),
-		}
-		for k, v := range row {
-			if k != "ts" {
-				resp.Trends[i].Values[k] = v
-			}
-		}
+	if err := c.get(ctx, "/v1/trends", params, trendsDecoder{&resp}); err != nil {
+		return nil, err
 	}
-
 	return &resp, nil
 }
@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is one in-flight fetch shared by every caller that asked for the
+// same key while it was running.
+type flight[T any] struct {
+	done    chan struct{}
+	val     T
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalescer collapses concurrent fetches of the same key into one call, so
+// N panels missing the cache at the same moment produce one API request
+// rather than N. Unlike a plain singleflight, the shared fetch is only
+// cancelled once every waiting caller has given up, so one panel navigating
+// away does not fail the others.
+type coalescer[T any] struct {
+	name  string // metrics label
+	mu    sync.Mutex
+	calls map[string]*flight[T]
+}
+
+func newCoalescer[T any](name string) *coalescer[T] {
+	return &coalescer[T]{name: name, calls: make(map[string]*flight[T])}
+}
+
+// do returns the result of fn for key, joining an in-flight call for the
+// same key if there is one. fn runs with a context that keeps the values of
+// the first caller's ctx but is cancelled only when all callers are done.
+func (g *coalescer[T]) do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	f, ok := g.calls[key]
+	if ok {
+		f.waiters++
+		g.mu.Unlock()
+		coalescedRequests.WithLabelValues(g.name).Inc()
+	} else {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight[T]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = f
+		g.mu.Unlock()
+
+		go func() {
+			f.val, f.err = fn(fctx)
+			cancel()
+			g.mu.Lock()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Abandoned: cancel it and let the next caller start afresh.
+			f.cancel()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		var zero T
+		return zero, ctx.Err()
+	}
+}
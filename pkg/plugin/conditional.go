@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"net/http"
+	"sync"
+)
+
+// maxValidatedBodies bounds how many metadata responses a Client keeps for
+// conditional GETs.
+const maxValidatedBodies = 512
+
+// conditionalPaths are the metadata endpoints fetched with conditional GETs.
+// Their payloads rarely change, so a 304 saves the whole transfer; trends
+// and live values change on every request and are never revalidated.
+var conditionalPaths = map[string]bool{
+	"/v1/project": true,
+	"/v1/zones":   true,
+	"/v1/spaces":  true,
+	"/v1/assets":  true,
+	"/v1/sources": true,
+	"/v1/points":  true,
+}
+
+// validatedBody is a decompressed response body with its cache validators.
+type validatedBody struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// validatorCache stores the last 200 response per request URL for the
+// conditionalPaths, so the next request can send If-None-Match /
+// If-Modified-Since and reuse the body on 304 Not Modified.
+type validatorCache struct {
+	mu      sync.Mutex
+	entries map[string]*validatedBody
+}
+
+func newValidatorCache() *validatorCache {
+	return &validatorCache{entries: make(map[string]*validatedBody)}
+}
+
+// get returns the stored body for url, or nil.
+func (c *validatorCache) get(url string) *validatedBody {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[url]
+}
+
+// put stores body for url if the response carried a validator. When full,
+// an arbitrary entry is evicted.
+func (c *validatorCache) put(url string, h http.Header, body []byte) {
+	v := &validatedBody{
+		etag:         h.Get("ETag"),
+		lastModified: h.Get("Last-Modified"),
+		body:         body,
+	}
+	if v.etag == "" && v.lastModified == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[url]; !ok && len(c.entries) >= maxValidatedBodies {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[url] = v
+}
+
+// clear drops every stored body.
+func (c *validatorCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*validatedBody)
+}
+
+// setConditionalHeaders adds the validators from v to req.
+func setConditionalHeaders(req *http.Request, v *validatedBody) {
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -18,44 +22,99 @@ var (
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
-// Datasource is the Novant data source plugin.
+// Datasource is the Novant data source plugin. It holds one or more Novant
+// projects; queries target the first project unless they name others.
 type Datasource struct {
-	client     *Client
-	pointCache *pointCache
-	valueCache *valueCache
+	projects       []*project
+	projectsByName map[string]*project
+	logLevel       logLevel
 }
 
 // NewDatasource creates a new Novant data source instance.
-func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
-	apiKey, ok := settings.DecryptedSecureJSONData["apiKey"]
-	if !ok || apiKey == "" {
-		return nil, fmt.Errorf("API key is required")
-	}
-	return &Datasource{
-		client:     NewClient(apiKey),
-		pointCache: newPointCache(),
-		valueCache: newValueCache(),
-	}, nil
+func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	s, err := loadSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := projectKeys(s, settings.DecryptedSecureJSONData)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := newHTTPClient(ctx, settings, s)
+	if err != nil {
+		return nil, err
+	}
+	level, _ := parseLogLevel(s.LogLevel) // validated by loadSettings
+
+	d := &Datasource{
+		projectsByName: make(map[string]*project, len(keys)),
+		logLevel:       level,
+	}
+	trends := newTrendCache(trendCacheBudget(s))
+	for _, k := range keys {
+		p := newProject(k[0], k[1], s, httpClient, trends)
+		d.projects = append(d.projects, p)
+		d.projectsByName[p.name] = p
+	}
+	return d, nil
 }
 
 // Dispose cleans up resources.
-func (d *Datasource) Dispose() {}
+func (d *Datasource) Dispose() {
+	// All projects share one HTTP client.
+	d.projects[0].client.Close()
+}
 
-// CheckHealth validates the data source configuration.
-func (d *Datasource) CheckHealth(_ context.Context, _ *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	proj, err := d.client.GetProject()
-	if err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: fmt.Sprintf("Failed to connect: %v", err),
-		}, nil
+// CheckHealth validates the data source configuration and reports
+// diagnostics for every configured project: API latency, point usage
+// against capacity, bound vs unbound sources, and access to /v1/points and
+// /v1/trends. The full report is returned in JSONDetails. The SDK has no
+// warning status, so a project near its capacity still reports OK with the
+// warning in the message.
+func (d *Datasource) CheckHealth(ctx context.Context, _ *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	ctx = withRequestLogger(ctx, d.logLevel)
+
+	details := healthDetails{Projects: make([]projectHealth, len(d.projects))}
+	var wg sync.WaitGroup
+	for i, p := range d.projects {
+		wg.Add(1)
+		go func(i int, p *project) {
+			defer wg.Done()
+			details.Projects[i] = d.checkProject(ctx, p)
+		}(i, p)
 	}
+	wg.Wait()
+
+	status := backend.HealthStatusOk
+	var summaries, verbose []string
+	for _, h := range details.Projects {
+		if h.Status == healthError {
+			status = backend.HealthStatusError
+		}
+		summaries = append(summaries, h.summary(len(d.projects) > 1))
+		verbose = append(verbose, h.verbose())
+	}
+	details.Message = strings.Join(summaries, "\n")
+	details.VerboseMessage = strings.Join(verbose, "\n")
+
 	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: fmt.Sprintf("Connected to %s (%s)", proj.ProjName, proj.City),
+		Status:      status,
+		Message:     details.Message,
+		JSONDetails: details.marshal(),
 	}, nil
 }
 
+// connectError formats a health check failure. The API endpoint is only
+// named when it has been overridden, since a wrong custom base URL is the
+// most likely cause of a failure in that case.
+func (d *Datasource) connectError(p *project, err error) string {
+	prefix := "Failed to connect"
+	if base := p.client.BaseURL(); base != defaultBaseURL {
+		return fmt.Sprintf("%s to %s: %v", prefix, base, err)
+	}
+	return fmt.Sprintf("%s: %v", prefix, err)
+}
+
 // CallResource handles HTTP calls to /api/datasources/uid/<uid>/resources/<path>.
 // Used by the data source config UI to clear the in-memory point name cache.
 func (d *Datasource) CallResource(_ context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
@@ -67,8 +126,9 @@ func (d *Datasource) CallResource(_ context.Context, req *backend.CallResourceRe
 				Body:   []byte(`{"error":"method not allowed"}`),
 			})
 		}
-		d.pointCache.clear()
-		d.valueCache.clear()
+		for _, p := range d.projects {
+			p.clear()
+		}
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusOK,
 			Body:   []byte(`{"status":"ok"}`),
@@ -82,115 +142,239 @@ func (d *Datasource) CallResource(_ context.Context, req *backend.CallResourceRe
 }
 
 // QueryData handles multiple queries.
-func (d *Datasource) QueryData(_ context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	ctx = withRequestLogger(ctx, d.logLevel)
+	logger := loggerFromContext(ctx)
 	response := backend.NewQueryDataResponse()
 	for _, q := range req.Queries {
-		response.Responses[q.RefID] = d.query(q)
+		start := time.Now()
+		qctx, stats := withQueryStats(ctx)
+		qctx, span := startSpan(qctx, "novant.query",
+			attribute.String("novant.query_type", q.QueryType),
+			attribute.String("novant.ref_id", q.RefID),
+			attribute.String("novant.time_from", q.TimeRange.From.Format(time.RFC3339)),
+			attribute.String("novant.time_to", q.TimeRange.To.Format(time.RFC3339)),
+		)
+		resp := d.query(qctx, q)
+		stats.annotate(&resp)
+		endSpan(span, resp.Error)
+		response.Responses[q.RefID] = resp
+
+		logArgs := []interface{}{
+			"refId", q.RefID,
+			"queryType", q.QueryType,
+			"from", q.TimeRange.From,
+			"to", q.TimeRange.To,
+			"duration", time.Since(start),
+			"frames", len(resp.Frames),
+		}
+		if resp.Error != nil {
+			logger.Warn("Query failed", append(logArgs, "status", resp.Status, "error", redact(resp.Error.Error()))...)
+		} else {
+			logger.Debug("Query", logArgs...)
+		}
 	}
 	return response, nil
 }
 
-func (d *Datasource) query(q backend.DataQuery) backend.DataResponse {
+func (d *Datasource) query(ctx context.Context, q backend.DataQuery) backend.DataResponse {
 	var qm QueryModel
 	if err := json.Unmarshal(q.JSON, &qm); err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err))
 	}
+	if err := ctx.Err(); err != nil {
+		return backend.ErrDataResponse(backend.StatusTimeout, err.Error())
+	}
+
+	projects, err := d.selectProjects(qm.Project)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
 
+	// Single-project data sources keep their frames untouched; with several
+	// projects every frame is tagged so one dashboard can compare buildings.
+	tag := len(d.projects) > 1
+	if len(projects) == 1 {
+		resp := projects[0].query(ctx, q, qm)
+		if tag && resp.Error == nil {
+			tagProject(resp.Frames, projects[0].label(ctx))
+		}
+		return resp
+	}
+
+	resps := make([]backend.DataResponse, len(projects))
+	var wg sync.WaitGroup
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project) {
+			defer wg.Done()
+			resps[i] = p.query(ctx, q, qm)
+			if resps[i].Error == nil {
+				tagProject(resps[i].Frames, p.label(ctx))
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	return mergeProjectResponses(projects, resps)
+}
+
+// query runs a single query against this project.
+func (p *project) query(ctx context.Context, q backend.DataQuery, qm QueryModel) backend.DataResponse {
 	switch q.QueryType {
 	case "zones":
-		return d.queryZones(qm)
+		return p.queryZones(ctx, qm)
 	case "spaces":
-		return d.querySpaces(qm)
+		return p.querySpaces(ctx, qm)
 	case "assets":
-		return d.queryAssets(qm)
+		return p.queryAssets(ctx, qm)
 	case "sources":
-		return d.querySources(qm)
+		return p.querySources(ctx, qm)
 	case "points":
-		return d.queryPoints(qm)
+		return p.queryPoints(ctx, qm)
 	case "values":
-		return d.queryValues(qm)
+		return p.queryValues(ctx, qm)
 	case "trends":
-		return d.queryTrends(q, qm)
+		return p.queryTrends(ctx, q, qm)
 	default:
 		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("unknown query type: %s", q.QueryType))
 	}
 }
 
-func (d *Datasource) queryZones(qm QueryModel) backend.DataResponse {
-	resp, err := d.client.GetZones(qm.ZoneIDs)
+func (p *project) queryZones(ctx context.Context, qm QueryModel) backend.DataResponse {
+	resp, err := p.client.GetZones(ctx, qm.ZoneIDs)
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		return errorResponse(err)
 	}
 	return backend.DataResponse{Frames: data.Frames{buildZonesFrame(resp)}}
 }
 
-func (d *Datasource) querySpaces(qm QueryModel) backend.DataResponse {
-	resp, err := d.client.GetSpaces(qm.SpaceIDs)
+func (p *project) querySpaces(ctx context.Context, qm QueryModel) backend.DataResponse {
+	resp, err := p.client.GetSpaces(ctx, qm.SpaceIDs)
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		return errorResponse(err)
 	}
 	return backend.DataResponse{Frames: data.Frames{buildSpacesFrame(resp)}}
 }
 
-func (d *Datasource) queryAssets(qm QueryModel) backend.DataResponse {
-	resp, err := d.client.GetAssets(qm.AssetIDs)
+func (p *project) queryAssets(ctx context.Context, qm QueryModel) backend.DataResponse {
+	resp, err := p.client.GetAssets(ctx, qm.AssetIDs)
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		return errorResponse(err)
 	}
 	return backend.DataResponse{Frames: data.Frames{buildAssetsFrame(resp)}}
 }
 
-func (d *Datasource) querySources(qm QueryModel) backend.DataResponse {
-	resp, err := d.client.GetSources(qm.SourceIDs, qm.BoundOnly)
+func (p *project) querySources(ctx context.Context, qm QueryModel) backend.DataResponse {
+	resp, err := p.client.GetSources(ctx, qm.SourceIDs, qm.BoundOnly)
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		return errorResponse(err)
 	}
 	return backend.DataResponse{Frames: data.Frames{buildSourcesFrame(resp)}}
 }
 
-func (d *Datasource) queryPoints(qm QueryModel) backend.DataResponse {
-	resp, err := d.client.GetPoints(qm.SourceID, qm.AssetID, qm.SpaceID, qm.PointIDs, qm.PointTypes)
+func (p *project) queryPoints(ctx context.Context, qm QueryModel) backend.DataResponse {
+	resp, err := p.client.GetPoints(ctx, qm.SourceID, qm.AssetID, qm.SpaceID, qm.PointIDs, qm.PointTypes)
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		return errorResponse(err)
 	}
 	return backend.DataResponse{Frames: data.Frames{buildPointsFrame(resp)}}
 }
 
-func (d *Datasource) queryValues(qm QueryModel) backend.DataResponse {
+func (p *project) queryValues(ctx context.Context, qm QueryModel) backend.DataResponse {
 	key := valueCacheKey(qm.SourceID, qm.AssetID, qm.SpaceID, qm.PointIDs, qm.PointTypes)
-	resp, err := d.valueCache.getOrFetch(key, func() (*ValuesResp, error) {
-		return d.client.GetValues(qm.SourceID, qm.AssetID, qm.SpaceID, qm.PointIDs, qm.PointTypes)
+	resp, err := p.valueCache.getOrFetch(ctx, key, func(ctx context.Context) (*ValuesResp, error) {
+		return p.client.GetValues(ctx, qm.SourceID, qm.AssetID, qm.SpaceID, qm.PointIDs, qm.PointTypes)
 	})
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+		return errorResponse(err)
 	}
 	pointIDs := make([]string, len(resp.Values))
 	for i, v := range resp.Values {
 		pointIDs[i] = v.ID
 	}
-	names := d.pointCache.resolveNames(d.client, pointIDs)
-	return backend.DataResponse{Frames: data.Frames{buildValuesFrame(resp, names)}}
+	names := p.pointCache.resolveNames(ctx, p.client, pointIDs)
+
+	_, span := startSpan(ctx, "novant.frames.build", attribute.String("novant.frame", "values"))
+	frame := buildValuesFrame(resp, names)
+	endSpan(span, nil)
+	return backend.DataResponse{Frames: data.Frames{frame}}
 }
 
-func (d *Datasource) queryTrends(q backend.DataQuery, qm QueryModel) backend.DataResponse {
+func (p *project) queryTrends(ctx context.Context, q backend.DataQuery, qm QueryModel) backend.DataResponse {
 	if qm.PointIDs == "" {
 		return backend.ErrDataResponse(backend.StatusBadRequest, "point_ids is required for trends")
 	}
+	if !validTrendFormat(qm.Format) {
+		return backend.ErrDataResponse(backend.StatusBadRequest,
+			fmt.Sprintf("invalid format %q: must be wide, long, multi, or alert", qm.Format))
+	}
 
-	startDate := q.TimeRange.From.Format("2006-01-02")
-	endDate := q.TimeRange.To.Format("2006-01-02")
+	// Rows are trimmed to the exact range below, so the requested days must
+	// be the project's local days covering it; UTC days can miss the rows
+	// of a short range near local midnight and mis-align day and month
+	// aggregates. time.Date keeps each boundary on local midnight across
+	// DST changes.
+	loc, locErr := p.location(ctx)
+	if locErr != nil {
+		loggerFromContext(ctx).Warn("Reading trends in UTC", "error", locErr)
+	}
+	from, to := q.TimeRange.From.In(loc), q.TimeRange.To.In(loc)
+	startDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	endDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	startDate, endDate := startDay.Format("2006-01-02"), endDay.Format("2006-01-02")
 
-	resp, err := d.client.GetTrends(qm.PointIDs, startDate, endDate, qm.Interval, qm.Aggregate)
+	interval, auto := resolveInterval(q, qm.Interval)
+
+	var resp *TrendsResp
+	var notices []data.Notice
+	var err error
+	// Days split in a fallback zone are not the project's days, so nothing
+	// is cached until the zone is known.
+	if p.trendCache.enabled() && dayBucketed(interval) && locErr == nil {
+		resp, notices, err = p.cachedTrends(ctx, qm.PointIDs, startDay, endDay, interval, qm.Aggregate, loc)
+	} else {
+		resp, notices, _, err = p.fetchTrends(ctx, qm.PointIDs, startDay, endDay, interval, qm.Aggregate)
+	}
 	if err != nil {
+		return errorResponse(err)
+	}
+	// Naive row timestamps are wall-clock times in the project's zone.
+	if err := resp.resolveTimes(trendLocation(resp.Tz, loc)); err != nil {
 		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
 	}
+	if dropped := resp.trim(q.TimeRange.From, q.TimeRange.To, interval); dropped > 0 {
+		loggerFromContext(ctx).Debug("Trimmed trend rows outside the query range", "dropped", dropped, "kept", len(resp.Ts))
+	}
 
-	names := d.pointCache.resolveNames(d.client, resp.PointIDs)
+	points := p.pointCache.resolvePoints(ctx, p.client, resp.PointIDs)
 
-	frames, err := buildTrendsFrames(resp, names)
+	_, span := startSpan(ctx, "novant.frames.build",
+		attribute.String("novant.frame", "trends"),
+		attribute.Int("novant.rows", len(resp.Ts)),
+		attribute.Int("novant.points", len(resp.PointIDs)),
+	)
+	opts := trendFrameOptions{format: qm.Format, coerceBools: qm.CoerceBools}
+	if qm.Format == formatAlert {
+		opts.labels = p.alertLabels(ctx, resp.PointIDs, points)
+	}
+	frames, err := buildTrendsFrames(resp, points, opts)
+	endSpan(span, err)
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
 	}
+	annotateInterval(frames, interval, auto, qm.Aggregate, startDate, endDate)
+	if locErr != nil {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Project time zone unavailable, days and timestamps are in UTC: %v", locErr),
+		})
+	}
+	if len(notices) > 0 {
+		if len(frames) == 0 {
+			frames = data.Frames{data.NewFrame("trends")}
+		}
+		frames[0].AppendNotices(notices...)
+	}
 
 	return backend.DataResponse{Frames: frames}
 }
@@ -0,0 +1,252 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+const testAPIKey = "ak_test"
+
+// newTestDatasource starts a fake API for b and returns a data source
+// pointed at it. jsonData holds extra data source settings, merged over
+// baseUrl.
+func newTestDatasource(t *testing.T, b *novanttest.Building, jsonData map[string]interface{}) (*Datasource, *novanttest.Server) {
+	t.Helper()
+	srv := novanttest.NewServer(b, testAPIKey)
+	t.Cleanup(srv.Close)
+
+	settings := map[string]interface{}{"baseUrl": srv.URL}
+	for k, v := range jsonData {
+		settings[k] = v
+	}
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                raw,
+		DecryptedSecureJSONData: map[string]string{"apiKey": testAPIKey},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := inst.(*Datasource)
+	t.Cleanup(ds.Dispose)
+	return ds, srv
+}
+
+// runQuery runs a single query of queryType with the given model over
+// [from, to].
+func runQuery(t *testing.T, ds *Datasource, queryType string, model map[string]interface{}, from, to time.Time) backend.DataResponse {
+	t.Helper()
+	raw, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:         "A",
+			QueryType:     queryType,
+			JSON:          raw,
+			TimeRange:     backend.TimeRange{From: from, To: to},
+			MaxDataPoints: 1000,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Responses["A"]
+}
+
+// stringColumn returns the string values of the named field.
+func stringColumn(t *testing.T, f *data.Frame, name string) []string {
+	t.Helper()
+	field, _ := f.FieldByName(name)
+	if field == nil {
+		t.Fatalf("frame %q has no field %q", f.Name, name)
+	}
+	out := make([]string, field.Len())
+	for i := range out {
+		if s, ok := field.ConcreteAt(i); ok {
+			out[i], _ = s.(string)
+		}
+	}
+	return out
+}
+
+func TestQueryTypes(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, _ := newTestDatasource(t, b, nil)
+
+	loc, _ := time.LoadLocation(b.Tz)
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+
+	tests := []struct {
+		name      string
+		queryType string
+		model     map[string]interface{}
+		frames    int
+		rows      int
+		ids       []string // expected "id" column, if set
+	}{
+		{"all zones", "zones", nil, 1, 2, []string{"z.1", "z.2"}},
+		{"zone filter", "zones", map[string]interface{}{"zoneIds": "z.2"}, 1, 1, []string{"z.2"}},
+		{"all spaces", "spaces", nil, 1, 4, []string{"sp.1", "sp.2", "sp.3", "sp.4"}},
+		{"space filter", "spaces", map[string]interface{}{"spaceIds": "sp.1,sp.3"}, 1, 2, []string{"sp.1", "sp.3"}},
+		{"all assets", "assets", nil, 1, 4, []string{"a.1", "a.2", "a.3", "a.4"}},
+		{"asset filter", "assets", map[string]interface{}{"assetIds": "a.4"}, 1, 1, []string{"a.4"}},
+		{"all sources", "sources", nil, 1, 4, []string{"s.1", "s.2", "s.3", "s.4"}},
+		{"bound sources", "sources", map[string]interface{}{"boundOnly": true}, 1, 3, []string{"s.1", "s.2", "s.3"}},
+		{"points by source", "points", map[string]interface{}{"sourceId": "s.1"}, 1, 6, nil},
+		{"points by asset", "points", map[string]interface{}{"assetId": "a.2"}, 1, 6, nil},
+		{"points by type", "points", map[string]interface{}{"sourceId": "s.1", "pointTypes": "bi"}, 1, 1, []string{"s.1.5"}},
+		{"values by source", "values", map[string]interface{}{"sourceId": "s.2"}, 1, 6, nil},
+		{"values by point", "values", map[string]interface{}{"pointIds": "s.1.1,s.3.2"}, 1, 2, nil},
+		{"trends wide", "trends", map[string]interface{}{"pointIds": "s.1.1,s.1.5,s.1.6", "interval": "1hr"}, 1, 24, nil},
+		{"trends long", "trends", map[string]interface{}{"pointIds": "s.1.1,s.2.1", "interval": "1hr", "format": "long"}, 1, 48, nil},
+		{"trends multi", "trends", map[string]interface{}{"pointIds": "s.1.1,s.2.1", "interval": "1hr", "format": "multi"}, 2, 24, nil},
+		{"trends alert", "trends", map[string]interface{}{"pointIds": "s.1.1,s.2.1", "interval": "1hr", "format": "alert"}, 2, 24, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := runQuery(t, ds, tt.queryType, tt.model, day, day.Add(24*time.Hour-time.Second))
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if len(resp.Frames) != tt.frames {
+				t.Fatalf("got %d frames, want %d", len(resp.Frames), tt.frames)
+			}
+			for _, f := range resp.Frames {
+				if rows, _ := f.RowLen(); rows != tt.rows {
+					t.Errorf("frame %q: %d rows, want %d", f.Name, rows, tt.rows)
+				}
+			}
+			if tt.ids != nil {
+				got := stringColumn(t, resp.Frames[0], "id")
+				if strings.Join(got, ",") != strings.Join(tt.ids, ",") {
+					t.Errorf("ids = %v, want %v", got, tt.ids)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryTrendsAlertLabels(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, _ := newTestDatasource(t, b, nil)
+
+	loc, _ := time.LoadLocation(b.Tz)
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	resp := runQuery(t, ds, "trends", map[string]interface{}{"pointIds": "s.3.1", "interval": "1hr", "format": "alert"},
+		day, day.Add(12*time.Hour))
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	want := data.Labels{
+		"point_id": "s.3.1", "point_name": "Temp 1", "point_type": "ai", "unit": "°F",
+		"source_id": "s.3", "source_name": "VAV-3 Controller",
+		"asset_id": "a.3", "asset_name": "VAV-3",
+		"space_id": "sp.3", "space_name": "Room 103",
+		"zone_id": "z.2", "zone_name": "Zone 2",
+	}
+	got := resp.Frames[0].Fields[1].Labels
+	if got.String() != want.String() {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+}
+
+func TestQueryAPIErrorBody(t *testing.T) {
+	ds, srv := newTestDatasource(t, novanttest.NewBuilding(novanttest.Options{}), nil)
+	srv.FailNext("/v1/zones", http.StatusBadRequest)
+
+	resp := runQuery(t, ds, "zones", nil, time.Now().Add(-time.Hour), time.Now())
+	if resp.Error == nil {
+		t.Fatal("expected an error")
+	}
+	if resp.Status != backend.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.Status, backend.StatusBadRequest)
+	}
+	// The gzip-encoded error body is decoded into the Novant code and
+	// message rather than surfaced as compressed bytes.
+	if msg := resp.Error.Error(); !strings.Contains(msg, "[bad_request]: Bad Request") {
+		t.Errorf("error = %q, want the decoded err_code and err_msg", msg)
+	}
+	if n := srv.Requests("/v1/zones"); n != 1 {
+		t.Errorf("%d requests, want 1 (400 is not retried)", n)
+	}
+}
+
+func TestQueryRateLimitRetry(t *testing.T) {
+	ds, srv := newTestDatasource(t, novanttest.NewBuilding(novanttest.Options{}), nil)
+	srv.RateLimit("/v1/assets", 1)
+
+	start := time.Now()
+	resp := runQuery(t, ds, "assets", nil, time.Now().Add(-time.Hour), time.Now())
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := srv.Requests("/v1/assets"); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", waited)
+	}
+}
+
+func TestQueryConditionalGet(t *testing.T) {
+	ds, srv := newTestDatasource(t, novanttest.NewBuilding(novanttest.Options{}), nil)
+
+	var first []string
+	for i := 0; i < 2; i++ {
+		resp := runQuery(t, ds, "spaces", nil, time.Now().Add(-time.Hour), time.Now())
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+		names := stringColumn(t, resp.Frames[0], "name")
+		if i == 0 {
+			first = names
+		} else if strings.Join(names, ",") != strings.Join(first, ",") {
+			t.Errorf("revalidated names = %v, want %v", names, first)
+		}
+	}
+	if n := srv.NotModified("/v1/spaces"); n != 1 {
+		t.Errorf("%d 304 responses, want 1", n)
+	}
+
+	// Clearing the caches drops the validators, so the next request
+	// downloads the full body again.
+	ds.projects[0].clear()
+	if resp := runQuery(t, ds, "spaces", nil, time.Now().Add(-time.Hour), time.Now()); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := srv.NotModified("/v1/spaces"); n != 1 {
+		t.Errorf("%d 304 responses after clear, want 1", n)
+	}
+}
+
+func TestClientNumericDeviceID(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	srv := novanttest.NewServer(b, testAPIKey)
+	defer srv.Close()
+
+	c := NewClient(testAPIKey, Settings{BaseURL: srv.URL}, nil)
+	resp, err := c.GetSources(context.Background(), "s.2,s.3", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]FlexString{"s.2": "2", "s.3": "1003"}
+	for _, s := range resp.Sources {
+		if s.DeviceID != want[s.ID] {
+			t.Errorf("%s device_id = %q, want %q", s.ID, s.DeviceID, want[s.ID])
+		}
+	}
+}
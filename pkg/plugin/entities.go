@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// entityIndex relates a project's sources, assets, spaces, and zones so a
+// point can be placed in the building.
+type entityIndex struct {
+	sources map[string]Source
+	assets  map[string]Asset
+	zones   map[string]Zone
+	// assetOfSource maps a source to its asset, from Source.ParentAssetID
+	// or Asset.SourceIDs.
+	assetOfSource map[string]string
+	// spaceOfAsset maps an asset to the space containing it.
+	spaceOfAsset map[string]Space
+	// zoneOfAsset maps an asset to a zone it feeds, for assets that are in
+	// no space with a parent zone.
+	zoneOfAsset map[string]string
+}
+
+// entityCache caches the project's entity metadata for alert labels. It is
+// refreshed on the point cache cadence; /v1 metadata requests are
+// conditional, so a refresh of unchanged metadata is cheap.
+type entityCache struct {
+	mu      sync.RWMutex
+	fetched time.Time
+	idx     *entityIndex
+	flights *coalescer[*entityIndex]
+}
+
+func newEntityCache() *entityCache {
+	return &entityCache{flights: newCoalescer[*entityIndex]("entities")}
+}
+
+// index returns the entity index, fetching it if missing or stale. If a
+// refresh fails the stale index is kept; with no index at all, nil is
+// returned and labels fall back to what the point cache knows.
+func (c *entityCache) index(ctx context.Context, client *Client) *entityIndex {
+	c.mu.RLock()
+	idx, fetched := c.idx, c.fetched
+	c.mu.RUnlock()
+	if idx != nil && time.Since(fetched) < pointCacheTTL {
+		recordCacheLookup("entities", true)
+		return idx
+	}
+	recordCacheLookup("entities", false)
+
+	fresh, err := c.flights.do(ctx, "entities", func(ctx context.Context) (*entityIndex, error) {
+		return fetchEntityIndex(ctx, client)
+	})
+	if err != nil {
+		loggerFromContext(ctx).Warn("Entity metadata fetch failed; alert labels will be incomplete", "error", redact(err.Error()))
+		return idx
+	}
+
+	c.mu.Lock()
+	c.idx, c.fetched = fresh, time.Now()
+	c.mu.Unlock()
+	return fresh
+}
+
+func (c *entityCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.idx != nil {
+		cacheEvictions.WithLabelValues("entities").Inc()
+	}
+	c.idx = nil
+}
+
+func fetchEntityIndex(ctx context.Context, client *Client) (*entityIndex, error) {
+	ctx, span := startSpan(ctx, "novant.cache.entities")
+	var err error
+	defer func() { endSpan(span, err) }()
+
+	sources, err := client.GetSources(ctx, "", false)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := client.GetAssets(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	spaces, err := client.GetSpaces(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	zones, err := client.GetZones(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.Int("novant.sources", len(sources.Sources)),
+		attribute.Int("novant.assets", len(assets.Assets)),
+	)
+
+	idx := &entityIndex{
+		sources:       make(map[string]Source, len(sources.Sources)),
+		assets:        make(map[string]Asset, len(assets.Assets)),
+		zones:         make(map[string]Zone, len(zones.Zones)),
+		assetOfSource: make(map[string]string),
+		spaceOfAsset:  make(map[string]Space),
+		zoneOfAsset:   make(map[string]string),
+	}
+	for _, a := range assets.Assets {
+		idx.assets[a.ID] = a
+		for _, sid := range a.SourceIDs {
+			idx.assetOfSource[sid] = a.ID
+		}
+	}
+	for _, s := range sources.Sources {
+		idx.sources[s.ID] = s
+		if s.ParentAssetID != "" {
+			idx.assetOfSource[s.ID] = s.ParentAssetID
+		}
+	}
+	for _, sp := range spaces.Spaces {
+		for _, aid := range sp.ContainsAssetIDs {
+			idx.spaceOfAsset[aid] = sp
+		}
+	}
+	for _, z := range zones.Zones {
+		idx.zones[z.ID] = z
+		for _, aid := range z.FedByAssetIDs {
+			if _, ok := idx.zoneOfAsset[aid]; !ok {
+				idx.zoneOfAsset[aid] = z.ID
+			}
+		}
+	}
+	return idx, nil
+}
+
+// alertLabels returns labels placing each point in the building: the point
+// itself, its source, the asset the source belongs to, and the space and
+// zone of that asset, as far as they are known. Empty values are left out.
+func (p *project) alertLabels(ctx context.Context, pointIDs []string, points map[string]Point) map[string]data.Labels {
+	idx := p.entityCache.index(ctx, p.client)
+
+	out := make(map[string]data.Labels, len(pointIDs))
+	for _, pid := range pointIDs {
+		labels := data.Labels{"point_id": pid}
+		set := func(k, v string) {
+			if v != "" {
+				labels[k] = v
+			}
+		}
+		if pt, ok := points[pid]; ok {
+			set("point_name", pt.Name)
+			set("point_type", pt.Type)
+			set("unit", pt.Unit)
+		}
+
+		sid := extractSourceID(pid)
+		set("source_id", sid)
+		if idx != nil {
+			set("source_name", idx.sources[sid].Name)
+			aid := idx.assetOfSource[sid]
+			set("asset_id", aid)
+			set("asset_name", idx.assets[aid].Name)
+
+			zid := ""
+			if sp, ok := idx.spaceOfAsset[aid]; ok {
+				set("space_id", sp.ID)
+				set("space_name", sp.Name)
+				zid = sp.ParentZoneID
+			}
+			if zid == "" {
+				zid = idx.zoneOfAsset[aid]
+			}
+			if z, ok := idx.zones[zid]; ok {
+				set("zone_id", z.ID)
+				set("zone_name", z.Name)
+			}
+		}
+		out[pid] = labels
+	}
+	return out
+}
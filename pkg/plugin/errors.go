@@ -0,0 +1,184 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// APIError is a non-200 response from the Novant API.
+type APIError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code and Message are the Novant error code and message, when the body
+	// is a JSON error object. Otherwise Message holds the raw body.
+	Code    string
+	Message string
+	// Path is the API path that was requested, e.g. "/v1/trends".
+	Path string
+	// RetryAfter is the parsed Retry-After header on 429/503, or zero.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "API error %d", e.StatusCode)
+	if e.Path != "" {
+		fmt.Fprintf(&b, " (%s)", e.Path)
+	}
+	if e.Code != "" {
+		fmt.Fprintf(&b, " [%s]", e.Code)
+	}
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+	return b.String()
+}
+
+// newAPIError builds an APIError from a non-200 response body. Novant error
+// bodies are JSON objects; the code and message keys are picked up when
+// present, and anything else falls back to the trimmed raw body.
+func newAPIError(statusCode int, path string, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode, Path: path}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err == nil {
+		e.Code = firstString(obj, "err_code", "code")
+		e.Message = firstString(obj, "err_msg", "message", "msg", "error")
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	// Error bodies end up in panels and logs; keep them short and make sure
+	// nothing that looks like an API key survives.
+	e.Message = redact(e.Message)
+	return e
+}
+
+// firstString returns the first non-empty value in obj among keys, formatted
+// as a string.
+func firstString(obj map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := obj[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%g", v)
+		}
+	}
+	return ""
+}
+
+// statusFromAPIStatus maps a Novant HTTP status to the Grafana status
+// reported on a failed query.
+func statusFromAPIStatus(code int) backend.Status {
+	switch code {
+	case http.StatusBadRequest:
+		return backend.StatusBadRequest
+	case http.StatusUnauthorized:
+		return backend.StatusUnauthorized
+	case http.StatusForbidden:
+		return backend.StatusForbidden
+	case http.StatusNotFound:
+		return backend.StatusNotFound
+	case http.StatusTooManyRequests:
+		return backend.StatusTooManyRequests
+	case http.StatusGatewayTimeout:
+		return backend.StatusTimeout
+	}
+	if code >= 500 {
+		return backend.StatusBadGateway
+	}
+	return backend.StatusInternal
+}
+
+// transientNetError reports whether err is a network failure another attempt
+// may get past: a timeout, a refused or reset connection, or a failed dial.
+// Certificate and TLS failures are never transient, even when they surface
+// during the dial.
+func transientNetError(err error) bool {
+	if configNetError(err) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// configNetError reports whether err comes from the data source's transport
+// settings rather than from Novant: an untrusted or invalid certificate, a
+// failed TLS handshake, or a proxy that refused the CONNECT.
+func configNetError(err error) bool {
+	var (
+		unknownAuth x509.UnknownAuthorityError
+		invalidCert x509.CertificateInvalidError
+		hostname    x509.HostnameError
+		systemRoots x509.SystemRootsError
+		verifyErr   *tls.CertificateVerificationError
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+	)
+	switch {
+	case errors.As(err, &unknownAuth), errors.As(err, &invalidCert),
+		errors.As(err, &hostname), errors.As(err, &systemRoots),
+		errors.As(err, &verifyErr), errors.As(err, &recordErr),
+		errors.As(err, &alertErr):
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		var ne net.Error
+		timeout := errors.As(opErr.Err, &ne) && ne.Timeout()
+		refused := errors.Is(opErr.Err, syscall.ECONNREFUSED) || errors.Is(opErr.Err, syscall.ECONNRESET)
+		return !timeout && !refused
+	}
+	return false
+}
+
+// errorResponse converts a Client error into a DataResponse with the right
+// Grafana status and error source, so alerting and the UI can tell a
+// misconfigured query (4xx) or data source (bad certificate or proxy) apart
+// from a Novant outage (5xx).
+func errorResponse(err error) backend.DataResponse {
+	var apiErr *APIError
+	var netErr net.Error
+	var fxErr *fixtureError
+	switch {
+	case errors.As(err, &apiErr):
+		return backend.ErrDataResponseWithSource(
+			statusFromAPIStatus(apiErr.StatusCode),
+			backend.ErrorSourceFromHTTPStatus(apiErr.StatusCode),
+			err.Error(),
+		)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return backend.ErrDataResponse(backend.StatusTimeout, err.Error())
+	case errors.As(err, &fxErr):
+		return backend.ErrDataResponse(backend.StatusNotFound, fxErr.Error())
+	case configNetError(err):
+		return backend.ErrDataResponse(backend.StatusBadRequest,
+			"connecting to the Novant API failed; check the data source URL, TLS and proxy settings: "+err.Error())
+	case errors.As(err, &netErr):
+		return backend.ErrDataResponseWithSource(backend.StatusBadGateway, backend.ErrorSourceDownstream, err.Error())
+	default:
+		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+	}
+}
@@ -192,53 +192,241 @@ func buildValuesFrame(resp *ValuesResp, names map[string]string) *data.Frame {
 	)
 }
 
-func buildTrendsFrames(resp *TrendsResp, names map[string]string) (data.Frames, error) {
-	if len(resp.Trends) == 0 || len(resp.PointIDs) == 0 {
-		return data.Frames{}, nil
+// annotateInterval records the trend request on each frame: the executed
+// query shown in the query inspector names the interval (and whether it was
+// picked automatically), Custom carries it for panels and transforms, and
+// the time field's interval lets Grafana detect gaps.
+func annotateInterval(frames data.Frames, interval string, auto bool, aggregate, startDate, endDate string) {
+	if aggregate == "" {
+		aggregate = "auto"
+	}
+	label := interval
+	if auto {
+		label += " (auto)"
 	}
+	executed := fmt.Sprintf("interval=%s aggregate=%s start_date=%s end_date=%s", label, aggregate, startDate, endDate)
+	step, fixed := intervalStep(interval)
 
-	count := len(resp.Trends)
-	timestamps := make([]time.Time, count)
+	for _, f := range frames {
+		if f.Meta == nil {
+			f.Meta = &data.FrameMeta{}
+		}
+		f.Meta.ExecutedQueryString = executed
+		if f.Meta.Custom == nil {
+			f.Meta.Custom = map[string]interface{}{}
+		}
+		if custom, ok := f.Meta.Custom.(map[string]interface{}); ok {
+			custom["interval"] = interval
+			custom["intervalAuto"] = auto
+		}
+		if fixed && interval != "1mo" && len(f.Fields) > 0 && f.Fields[0].Type().Time() {
+			f.Fields[0].SetConfig(&data.FieldConfig{Interval: float64(step.Milliseconds())})
+		}
+	}
+}
 
-	// Parse timestamps
-	for i, row := range resp.Trends {
-		t, err := time.Parse(time.RFC3339, row.Ts)
-		if err != nil {
-			// Try alternate formats
-			t, err = time.Parse("2006-01-02T15:04:05", row.Ts)
-			if err != nil {
-				return nil, fmt.Errorf("parsing timestamp %q: %w", row.Ts, err)
-			}
+// Trend frame formats, selected by QueryModel.Format.
+const (
+	formatWide  = "wide"
+	formatLong  = "long"
+	formatMulti = "multi"
+	formatAlert = "alert"
+)
+
+// validTrendFormat reports whether format is a supported trends format;
+// empty selects wide.
+func validTrendFormat(format string) bool {
+	switch format {
+	case "", formatWide, formatLong, formatMulti, formatAlert:
+		return true
+	}
+	return false
+}
+
+// trendFrameOptions shapes the trends frames.
+type trendFrameOptions struct {
+	format      string
+	coerceBools bool
+	// labels holds each point's alert labels for the alert format.
+	labels map[string]data.Labels
+}
+
+// buildTrendsFrames builds the trends frames in the requested format, each
+// point's field typed per trendFieldKind. points holds the metadata of the
+// points found in the point cache. Frames carry the matching dataplane
+// type, so alert rules and transforms read them without guessing; wide and
+// multi frames with bool or string fields carry none:
+//
+//   - wide: one frame, a time field plus one field per point
+//   - long: one frame of (time, point_id, name, value) rows
+//   - multi: one frame per point, a time field plus its value field
+//   - alert: multi, but every value is numeric and each frame is labelled
+//     with the point's place in the building, giving one alert instance
+//     per point
+func buildTrendsFrames(resp *TrendsResp, points map[string]Point, opts trendFrameOptions) (data.Frames, error) {
+	if len(resp.Ts) == 0 || len(resp.PointIDs) == 0 {
+		return data.Frames{}, nil
+	}
+
+	if resp.Times == nil {
+		if err := resp.resolveTimes(time.UTC); err != nil {
+			return nil, err
 		}
-		timestamps[i] = t
 	}
 
-	// Build one field per point ID. Field name is the point's display name (when known)
-	// so the panel legend reads naturally; the raw point ID is preserved as a label
-	// for transforms / overrides.
-	fields := make([]*data.Field, 0, len(resp.PointIDs)+1)
-	fields = append(fields, data.NewField("time", nil, timestamps))
+	// Field name is the point's display name (when known) so the panel
+	// legend reads naturally; the raw point ID is preserved as a label for
+	// transforms / overrides.
+	names := pointNames(resp.PointIDs, points)
+	switch opts.format {
+	case formatLong:
+		return data.Frames{buildTrendsLongFrame(resp, names)}, nil
+	case formatMulti:
+		return buildTrendsMultiFrames(resp, points, names, opts.coerceBools), nil
+	case formatAlert:
+		return buildTrendsAlertFrames(resp, names, opts.labels), nil
+	}
+	return data.Frames{buildTrendsWideFrame(resp, points, names, opts.coerceBools)}, nil
+}
 
+func trendPointField(resp *TrendsResp, pid string, points map[string]Point, names map[string]string, coerceBools bool) *data.Field {
+	s := resp.Series[pid]
+	p, known := points[pid]
+	kind := trendFieldKind(p, known, s)
+	return data.NewField(names[pid], data.Labels{"point_id": pid}, trendValues(s, len(resp.Ts), kind, coerceBools))
+}
+
+func buildTrendsWideFrame(resp *TrendsResp, points map[string]Point, names map[string]string, coerceBools bool) *data.Frame {
+	fields := make([]*data.Field, 0, len(resp.PointIDs)+1)
+	fields = append(fields, data.NewField("time", nil, resp.Times))
 	for _, pid := range resp.PointIDs {
-		values := make([]*float64, count)
-		for i, row := range resp.Trends {
-			if v, ok := row.Values[pid]; ok {
-				if f, ok := v.(float64); ok {
-					values[i] = &f
+		fields = append(fields, trendPointField(resp, pid, points, names, coerceBools))
+	}
+
+	frame := data.NewFrame("trends", fields...)
+	frame.Meta = timeSeriesMeta(data.FrameTypeTimeSeriesWide, fields)
+	return frame
+}
+
+// timeSeriesMeta returns the meta of a wide or multi trends frame. The
+// dataplane contract only allows numeric value fields, so a frame holding
+// bool or string fields is left untyped and panels read it as plain data.
+func timeSeriesMeta(frameType data.FrameType, fields []*data.Field) *data.FrameMeta {
+	for _, f := range fields[1:] {
+		if !f.Type().Numeric() {
+			return &data.FrameMeta{}
+		}
+	}
+	return &data.FrameMeta{
+		Type:                   frameType,
+		TypeVersion:            data.FrameTypeVersion{0, 1},
+		PreferredVisualization: data.VisTypeGraph,
+	}
+}
+
+// buildTrendsLongFrame builds one row per timestamp and point, in time
+// order. A long frame has a single value column, so it is numeric: bool
+// samples are 0/1 and string samples are parsed as numbers where possible.
+func buildTrendsLongFrame(resp *TrendsResp, names map[string]string) *data.Frame {
+	rows := len(resp.Ts) * len(resp.PointIDs)
+	times := make([]time.Time, 0, rows)
+	ids := make([]string, 0, rows)
+	nameCol := make([]string, 0, rows)
+	values := make([]*float64, 0, rows)
+	for i, t := range resp.Times {
+		for _, pid := range resp.PointIDs {
+			times = append(times, t)
+			ids = append(ids, pid)
+			nameCol = append(nameCol, names[pid])
+			var v *float64
+			if s, ok := resp.Series[pid]; ok {
+				if f, ok := sampleFloat(s, i); ok {
+					v = &f
 				}
 			}
+			values = append(values, v)
 		}
-		name := pid
-		if n, ok := names[pid]; ok {
-			name = n
-		}
-		fields = append(fields, data.NewField(name, data.Labels{"point_id": pid}, values))
 	}
 
-	frame := data.NewFrame("trends", fields...)
+	frame := data.NewFrame("trends",
+		data.NewField("time", nil, times),
+		data.NewField("point_id", nil, ids),
+		data.NewField("name", nil, nameCol),
+		data.NewField("value", nil, values),
+	)
 	frame.Meta = &data.FrameMeta{
+		Type:                   data.FrameTypeTimeSeriesLong,
+		TypeVersion:            data.FrameTypeVersion{0, 1},
 		PreferredVisualization: data.VisTypeGraph,
 	}
+	return frame
+}
+
+// buildTrendsMultiFrames builds one frame per point, each with its own copy
+// of the time field.
+func buildTrendsMultiFrames(resp *TrendsResp, points map[string]Point, names map[string]string, coerceBools bool) data.Frames {
+	frames := make(data.Frames, 0, len(resp.PointIDs))
+	for _, pid := range resp.PointIDs {
+		frame := data.NewFrame(names[pid],
+			data.NewField("time", nil, resp.Times),
+			trendPointField(resp, pid, points, names, coerceBools),
+		)
+		frame.Meta = timeSeriesMeta(data.FrameTypeTimeSeriesMulti, frame.Fields)
+		frames = append(frames, frame)
+	}
+	return frames
+}
 
-	return data.Frames{frame}, nil
+// buildTrendsAlertFrames builds one numeric frame per point for alert
+// rules. Bool samples are 0/1 and string samples are parsed as numbers
+// where possible, so every point yields a series alert conditions can
+// reduce. A point with samples but none numeric, such as a multistate
+// point reporting its state names, is dropped with a warning notice rather
+// than sent as an all-null series. labels supplies the value field labels;
+// points without labels get just point_id.
+func buildTrendsAlertFrames(resp *TrendsResp, names map[string]string, labels map[string]data.Labels) data.Frames {
+	frames := make(data.Frames, 0, len(resp.PointIDs))
+	var dropped []string
+	for _, pid := range resp.PointIDs {
+		values := make([]*float64, len(resp.Ts))
+		var samples, numeric int
+		if s, ok := resp.Series[pid]; ok {
+			for i := range values {
+				if s.Kinds[i] != SampleNull {
+					samples++
+				}
+				if f, ok := sampleFloat(s, i); ok {
+					values[i] = &f
+					numeric++
+				}
+			}
+		}
+		if samples > 0 && numeric == 0 {
+			dropped = append(dropped, names[pid])
+			continue
+		}
+		l, ok := labels[pid]
+		if !ok {
+			l = data.Labels{"point_id": pid}
+		}
+		frame := data.NewFrame(names[pid],
+			data.NewField("time", nil, resp.Times),
+			data.NewField(names[pid], l, values),
+		)
+		frame.Meta = &data.FrameMeta{
+			Type:        data.FrameTypeTimeSeriesMulti,
+			TypeVersion: data.FrameTypeVersion{0, 1},
+		}
+		frames = append(frames, frame)
+	}
+	if len(dropped) > 0 {
+		if len(frames) == 0 {
+			frames = data.Frames{data.NewFrame("trends")}
+		}
+		frames[0].AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "Points without numeric values left out of the alert frames: " + strings.Join(dropped, ", "),
+		})
+	}
+	return frames
 }
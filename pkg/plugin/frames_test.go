@@ -0,0 +1,261 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// testTrends builds a three-row hourly response with one series per entry
+// of series, in ids order.
+func testTrends(ids []string, series map[string][]interface{}) *TrendsResp {
+	resp := &TrendsResp{Tz: "UTC", Interval: "1hr", PointIDs: ids, Series: map[string]*TrendSeries{}}
+	for i := 0; i < 3; i++ {
+		resp.Ts = append(resp.Ts, fmt.Sprintf("2026-01-01T%02d:00:00", i))
+	}
+	for _, id := range ids {
+		s := &TrendSeries{}
+		for _, v := range series[id] {
+			s.append(v)
+		}
+		resp.Series[id] = s
+	}
+	return resp
+}
+
+// fieldValues returns the field's values with nulls as nil and pointers
+// dereferenced.
+func fieldValues(f *data.Field) []interface{} {
+	out := make([]interface{}, f.Len())
+	for i := range out {
+		if v, ok := f.ConcreteAt(i); ok {
+			out[i] = v
+		}
+	}
+	return out
+}
+
+func TestTrendFieldTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		kind        string // point kind in the point cache; empty if unknown
+		samples     []interface{}
+		coerceBools bool
+		wantType    data.FieldType
+		want        []interface{}
+	}{
+		{"numbers", "", []interface{}{1.5, nil, 2.0}, false, data.FieldTypeNullableFloat64, []interface{}{1.5, nil, 2.0}},
+		{"all null", "", []interface{}{nil, nil, nil}, false, data.FieldTypeNullableFloat64, []interface{}{nil, nil, nil}},
+		{"observed bools", "", []interface{}{true, false, nil}, false, data.FieldTypeNullableBool, []interface{}{true, false, nil}},
+		{"observed strings", "", []interface{}{"on", "off", 1.0}, false, data.FieldTypeNullableString, []interface{}{"on", "off", "1"}},
+		{"binary kind reported as 0/1", "binary", []interface{}{0.0, 1.0, nil}, false, data.FieldTypeNullableBool, []interface{}{false, true, nil}},
+		{"enum kind reported as numbers", "multistate", []interface{}{1.0, 2.0, 3.0}, false, data.FieldTypeNullableString, []interface{}{"1", "2", "3"}},
+		{"numeric kind with a stray string", "num", []interface{}{1.0, "2.5", "n/a"}, false, data.FieldTypeNullableFloat64, []interface{}{1.0, 2.5, nil}},
+		{"unknown kind uses samples", "weird", []interface{}{true, true, 1.0}, false, data.FieldTypeNullableBool, []interface{}{true, true, true}},
+		{"bools coerced", "bool", []interface{}{true, false, nil}, true, data.FieldTypeNullableFloat64, []interface{}{1.0, 0.0, nil}},
+		{"coercion leaves strings", "string", []interface{}{"a", true, nil}, true, data.FieldTypeNullableString, []interface{}{"a", "true", nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testTrends([]string{"s.1.1"}, map[string][]interface{}{"s.1.1": tt.samples})
+			points := map[string]Point{}
+			if tt.kind != "" {
+				points["s.1.1"] = Point{ID: "s.1.1", Name: "Fan", Kind: tt.kind}
+			}
+			frames, err := buildTrendsFrames(resp, points, trendFrameOptions{coerceBools: tt.coerceBools})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := frames[0].Fields[1]
+			if f.Type() != tt.wantType {
+				t.Fatalf("type = %s, want %s", f.Type(), tt.wantType)
+			}
+			got := fieldValues(f)
+			for i, w := range tt.want {
+				if got[i] != w {
+					t.Errorf("row %d = %v, want %v", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+// Wide and multi frames only claim a dataplane type when every value field
+// is numeric.
+func TestTrendsFrameMeta(t *testing.T) {
+	series := map[string][]interface{}{
+		"s.1.1": {70.0, 71.0, 72.0},
+		"s.1.2": {true, false, true},
+		"s.1.3": {"heat", "cool", "off"},
+	}
+	tests := []struct {
+		name        string
+		format      string
+		ids         []string
+		coerceBools bool
+		want        []data.FrameType // one per frame
+	}{
+		{"wide numeric", formatWide, []string{"s.1.1"}, false, []data.FrameType{data.FrameTypeTimeSeriesWide}},
+		{"default is wide", "", []string{"s.1.1"}, false, []data.FrameType{data.FrameTypeTimeSeriesWide}},
+		{"wide with a bool", formatWide, []string{"s.1.1", "s.1.2"}, false, []data.FrameType{""}},
+		{"wide with coerced bools", formatWide, []string{"s.1.1", "s.1.2"}, true, []data.FrameType{data.FrameTypeTimeSeriesWide}},
+		{"wide with a string", formatWide, []string{"s.1.1", "s.1.3"}, true, []data.FrameType{""}},
+		{"multi types each frame", formatMulti, []string{"s.1.1", "s.1.2", "s.1.3"}, false,
+			[]data.FrameType{data.FrameTypeTimeSeriesMulti, "", ""}},
+		{"multi with coerced bools", formatMulti, []string{"s.1.1", "s.1.2", "s.1.3"}, true,
+			[]data.FrameType{data.FrameTypeTimeSeriesMulti, data.FrameTypeTimeSeriesMulti, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testTrends(tt.ids, series)
+			frames, err := buildTrendsFrames(resp, nil, trendFrameOptions{format: tt.format, coerceBools: tt.coerceBools})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(frames) != len(tt.want) {
+				t.Fatalf("%d frames, want %d", len(frames), len(tt.want))
+			}
+			for i, f := range frames {
+				if f.Meta.Type != tt.want[i] {
+					t.Errorf("frame %d type = %q, want %q", i, f.Meta.Type, tt.want[i])
+				}
+				if f.Meta.Type != "" && f.Meta.TypeVersion != (data.FrameTypeVersion{0, 1}) {
+					t.Errorf("frame %d type version = %v", i, f.Meta.TypeVersion)
+				}
+				if rows, _ := f.RowLen(); rows != 3 {
+					t.Errorf("frame %d has %d rows, want 3", i, rows)
+				}
+				if !f.Fields[0].At(0).(time.Time).Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("frame %d starts at %v", i, f.Fields[0].At(0))
+				}
+			}
+		})
+	}
+}
+
+// A long frame has one numeric value column, ordered by time then point.
+func TestTrendsLongFrame(t *testing.T) {
+	resp := testTrends([]string{"s.1.1", "s.1.2", "s.1.3"}, map[string][]interface{}{
+		"s.1.1": {70.0, nil, 72.0},
+		"s.1.2": {true, false, nil},
+		"s.1.3": {"4.5", "off", nil},
+	})
+	points := map[string]Point{"s.1.1": {ID: "s.1.1", Name: "Temp"}}
+	frames, err := buildTrendsFrames(resp, points, trendFrameOptions{format: formatLong})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 {
+		t.Fatalf("%d frames, want 1", len(frames))
+	}
+	f := frames[0]
+	if f.Meta.Type != data.FrameTypeTimeSeriesLong {
+		t.Errorf("type = %q, want %q", f.Meta.Type, data.FrameTypeTimeSeriesLong)
+	}
+	if f.Fields[3].Type() != data.FieldTypeNullableFloat64 {
+		t.Errorf("value type = %s, want nullable float64", f.Fields[3].Type())
+	}
+	want := []struct {
+		hour     int
+		id, name string
+		value    interface{}
+	}{
+		{0, "s.1.1", "Temp", 70.0}, {0, "s.1.2", "s.1.2", 1.0}, {0, "s.1.3", "s.1.3", 4.5},
+		{1, "s.1.1", "Temp", nil}, {1, "s.1.2", "s.1.2", 0.0}, {1, "s.1.3", "s.1.3", nil},
+		{2, "s.1.1", "Temp", 72.0}, {2, "s.1.2", "s.1.2", nil}, {2, "s.1.3", "s.1.3", nil},
+	}
+	if rows, _ := f.RowLen(); rows != len(want) {
+		t.Fatalf("%d rows, want %d", rows, len(want))
+	}
+	values := fieldValues(f.Fields[3])
+	for i, w := range want {
+		ts := time.Date(2026, 1, 1, w.hour, 0, 0, 0, time.UTC)
+		if !f.Fields[0].At(i).(time.Time).Equal(ts) || f.Fields[1].At(i) != w.id || f.Fields[2].At(i) != w.name || values[i] != w.value {
+			t.Errorf("row %d = %v %v %v %v, want %v %s %s %v", i,
+				f.Fields[0].At(i), f.Fields[1].At(i), f.Fields[2].At(i), values[i], ts, w.id, w.name, w.value)
+		}
+	}
+}
+
+// Alert frames are numeric multi frames, one per point; points with no
+// numeric samples are dropped with a notice.
+func TestTrendsAlertFrames(t *testing.T) {
+	series := map[string][]interface{}{
+		"s.1.1": {70.0, nil, 72.0},
+		"s.1.2": {true, false, nil},
+		"s.1.3": {"heat", "cool", "off"},
+		"s.1.4": {"12.5", "n/a", nil},
+		"s.1.5": {nil, nil, nil},
+	}
+	labels := map[string]data.Labels{"s.1.1": {"point_id": "s.1.1", "asset_name": "AHU-1"}}
+	tests := []struct {
+		name    string
+		ids     []string
+		want    map[string][]interface{} // value rows per kept point
+		dropped string                   // notice text suffix; empty for none
+	}{
+		{"numbers and bools", []string{"s.1.1", "s.1.2"},
+			map[string][]interface{}{"s.1.1": {70.0, nil, 72.0}, "s.1.2": {1.0, 0.0, nil}}, ""},
+		{"numeric strings parsed", []string{"s.1.4"},
+			map[string][]interface{}{"s.1.4": {12.5, nil, nil}}, ""},
+		{"empty point kept", []string{"s.1.5"},
+			map[string][]interface{}{"s.1.5": {nil, nil, nil}}, ""},
+		{"state names dropped", []string{"s.1.1", "s.1.3"},
+			map[string][]interface{}{"s.1.1": {70.0, nil, 72.0}}, "s.1.3"},
+		{"only state names", []string{"s.1.3"}, nil, "s.1.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testTrends(tt.ids, series)
+			frames, err := buildTrendsFrames(resp, nil, trendFrameOptions{format: formatAlert, labels: labels})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kept int
+			for _, f := range frames {
+				if len(f.Fields) == 0 {
+					continue
+				}
+				kept++
+				if f.Meta.Type != data.FrameTypeTimeSeriesMulti {
+					t.Errorf("%s: type = %q", f.Name, f.Meta.Type)
+				}
+				v := f.Fields[1]
+				pid := v.Labels["point_id"]
+				if v.Type() != data.FieldTypeNullableFloat64 {
+					t.Errorf("%s: value type = %s", pid, v.Type())
+				}
+				if pid == "s.1.1" && v.Labels["asset_name"] != "AHU-1" {
+					t.Errorf("s.1.1 labels = %v", v.Labels)
+				}
+				want, ok := tt.want[pid]
+				if !ok {
+					t.Errorf("unexpected frame for %s", pid)
+					continue
+				}
+				got := fieldValues(v)
+				for i, w := range want {
+					if got[i] != w {
+						t.Errorf("%s row %d = %v, want %v", pid, i, got[i], w)
+					}
+				}
+			}
+			if kept != len(tt.want) {
+				t.Errorf("%d frames with values, want %d", kept, len(tt.want))
+			}
+			var notices []data.Notice
+			if len(frames) > 0 && frames[0].Meta != nil {
+				notices = frames[0].Meta.Notices
+			}
+			switch {
+			case tt.dropped == "" && len(notices) > 0:
+				t.Errorf("unexpected notices: %v", notices)
+			case tt.dropped != "" && (len(notices) != 1 || !strings.HasSuffix(notices[0].Text, ": "+tt.dropped)):
+				t.Errorf("notices = %v, want one naming %s", notices, tt.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// usageWarnPercent is the share of the project's point capacity above which
+// the health check warns.
+const usageWarnPercent = 90
+
+// Health check outcomes for a single check or project.
+const (
+	healthOK      = "ok"
+	healthWarning = "warning"
+	healthError   = "error"
+	healthSkipped = "skipped"
+)
+
+// healthDetails is the CheckHealthResult.JSONDetails payload. Message and
+// VerboseMessage are the keys Grafana's config page renders under the test
+// result; Projects carries the structured diagnostics.
+type healthDetails struct {
+	Message        string          `json:"message,omitempty"`
+	VerboseMessage string          `json:"verboseMessage,omitempty"`
+	Projects       []projectHealth `json:"projects"`
+}
+
+// projectHealth is the diagnostic report for one configured project.
+type projectHealth struct {
+	Name           string            `json:"name"`
+	Status         string            `json:"status"`
+	ProjectName    string            `json:"projectName,omitempty"`
+	City           string            `json:"city,omitempty"`
+	Tz             string            `json:"tz,omitempty"`
+	LatencyMs      int64             `json:"latencyMs"`
+	Usage          int               `json:"usage"`
+	Capacity       int               `json:"capacity"`
+	UsagePercent   float64           `json:"usagePercent"`
+	BoundSources   int               `json:"boundSources"`
+	UnboundSources int               `json:"unboundSources"`
+	Checks         map[string]string `json:"checks"`
+	Warnings       []string          `json:"warnings,omitempty"`
+	Errors         []string          `json:"errors,omitempty"`
+}
+
+// checkProject runs the health diagnostics for one project: /v1/project
+// round-trip latency and quota, bound vs unbound sources, and whether the
+// key can read /v1/points and /v1/trends. Requests go straight to the
+// client so no cache can mask a failure, and are not retried. The latency
+// is that of the single /v1/project attempt, without rate limiter waits.
+func (d *Datasource) checkProject(ctx context.Context, p *project) projectHealth {
+	ctx = withoutRetries(ctx)
+	h := projectHealth{
+		Name:   p.name,
+		Status: healthOK,
+		Checks: map[string]string{
+			"project": healthSkipped,
+			"sources": healthSkipped,
+			"points":  healthSkipped,
+			"trends":  healthSkipped,
+		},
+	}
+	fail := func(check string, err error) {
+		h.Checks[check] = healthError
+		h.Status = healthError
+		h.Errors = append(h.Errors, fmt.Sprintf("%s: %v", check, err))
+	}
+
+	pctx, stats := withQueryStats(ctx)
+	proj, err := p.client.GetProject(pctx)
+	h.LatencyMs = stats.lastRoundTrip().Milliseconds()
+	if err != nil {
+		h.Checks["project"] = healthError
+		h.Status = healthError
+		h.Errors = append(h.Errors, d.connectError(p, err))
+		return h
+	}
+	p.infoMu.Lock()
+	p.info = proj
+	p.infoMu.Unlock()
+
+	h.Checks["project"] = healthOK
+	h.ProjectName, h.City, h.Tz = proj.ProjName, proj.City, proj.Tz
+	h.Usage, h.Capacity = proj.Usage, proj.Capacity
+	if proj.Capacity > 0 {
+		h.UsagePercent = float64(proj.Usage) * 100 / float64(proj.Capacity)
+		if h.UsagePercent >= usageWarnPercent {
+			h.Status = healthWarning
+			h.Warnings = append(h.Warnings, fmt.Sprintf("point usage %d of %d (%.0f%%) is near the project capacity",
+				proj.Usage, proj.Capacity, h.UsagePercent))
+		}
+	}
+
+	sources, err := p.client.GetSources(ctx, "", false)
+	if err != nil {
+		fail("sources", err)
+		return h
+	}
+	h.Checks["sources"] = healthOK
+	var probe string
+	for _, s := range sources.Sources {
+		if s.Bound {
+			h.BoundSources++
+			if probe == "" {
+				probe = s.ID
+			}
+		} else {
+			h.UnboundSources++
+		}
+	}
+	if probe == "" {
+		h.Warnings = append(h.Warnings, "no bound sources; points and trends were not checked")
+		if h.Status == healthOK {
+			h.Status = healthWarning
+		}
+		return h
+	}
+
+	points, err := p.client.GetPoints(ctx, probe, "", "", "", "")
+	if err != nil {
+		fail("points", err)
+		return h
+	}
+	h.Checks["points"] = healthOK
+	if len(points.Points) == 0 {
+		return h
+	}
+
+	loc, err := p.location(ctx)
+	if err != nil {
+		h.Status = healthWarning
+		h.Warnings = append(h.Warnings, fmt.Sprintf("%v; trends would be read in UTC", err))
+	}
+	day := time.Now().In(loc).Format("2006-01-02")
+	if _, err := p.client.GetTrends(ctx, points.Points[0].ID, day, day, "1hr", "", loc); err != nil {
+		fail("trends", err)
+		return h
+	}
+	h.Checks["trends"] = healthOK
+	return h
+}
+
+// summary is the one-line health message for the project.
+func (h projectHealth) summary(multi bool) string {
+	var b strings.Builder
+	if multi {
+		fmt.Fprintf(&b, "[%s] ", h.Name)
+	}
+	if h.Checks["project"] != healthOK {
+		b.WriteString(strings.Join(h.Errors, "; "))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Connected to %s (%s) in %dms", h.ProjectName, h.City, h.LatencyMs)
+	if len(h.Errors) > 0 {
+		fmt.Fprintf(&b, "; %s", strings.Join(h.Errors, "; "))
+	}
+	if len(h.Warnings) > 0 {
+		fmt.Fprintf(&b, "; warning: %s", strings.Join(h.Warnings, "; "))
+	}
+	return b.String()
+}
+
+// verbose renders the full diagnostic report for the project.
+func (h projectHealth) verbose() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Project %q: %s\n", h.Name, h.Status)
+	if h.ProjectName != "" {
+		fmt.Fprintf(&b, "  Novant project: %s (%s), timezone %s\n", h.ProjectName, h.City, h.Tz)
+	}
+	fmt.Fprintf(&b, "  Latency: %dms\n", h.LatencyMs)
+	if h.Capacity > 0 {
+		fmt.Fprintf(&b, "  Points: %d of %d (%.1f%%)\n", h.Usage, h.Capacity, h.UsagePercent)
+	}
+	if h.Checks["sources"] == healthOK {
+		fmt.Fprintf(&b, "  Sources: %d bound, %d unbound\n", h.BoundSources, h.UnboundSources)
+	}
+	for _, c := range []string{"project", "sources", "points", "trends"} {
+		fmt.Fprintf(&b, "  /v1/%s: %s\n", c, h.Checks[c])
+	}
+	for _, w := range h.Warnings {
+		fmt.Fprintf(&b, "  Warning: %s\n", w)
+	}
+	for _, e := range h.Errors {
+		fmt.Fprintf(&b, "  Error: %s\n", e)
+	}
+	return b.String()
+}
+
+func (d healthDetails) marshal() []byte {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil
+	}
+	return b
+}
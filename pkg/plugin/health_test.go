@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+func TestCheckHealth(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, srv := newTestDatasource(t, b, nil)
+
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusOk {
+		t.Fatalf("status = %v: %s", res.Status, res.Message)
+	}
+	for _, path := range []string{"/v1/project", "/v1/sources", "/v1/points", "/v1/trends"} {
+		if n := srv.Requests(path); n != 1 {
+			t.Errorf("%s: %d requests, want 1", path, n)
+		}
+	}
+}
+
+// A failing API fails the health check on the first attempt rather than
+// after the retry budget.
+func TestCheckHealthNoRetry(t *testing.T) {
+	ds, srv := newTestDatasource(t, novanttest.NewBuilding(novanttest.Options{}), nil)
+	srv.FailNext("/v1/project", http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+	start := time.Now()
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusError {
+		t.Errorf("status = %v, want error", res.Status)
+	}
+	if n := srv.Requests("/v1/project"); n != 1 {
+		t.Errorf("%d /v1/project requests, want 1", n)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("health check took %v", d)
+	}
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+// newHTTPClient builds the http.Client used to reach the Novant API from the
+// HTTP settings Grafana supplies for the data source: request timeout,
+// proxy (including Grafana's secure SOCKS proxy), custom root CAs, mTLS
+// client certificates, and extra headers. s supplies the plugin's own
+// settings for clientMiddlewares.
+func newHTTPClient(ctx context.Context, settings backend.DataSourceInstanceSettings, s Settings) (*http.Client, error) {
+	opts, err := settings.HTTPClientOptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading HTTP client settings: %w", err)
+	}
+
+	// The Novant API key is sent as the basic auth user by Client.get; a
+	// Grafana basic auth setting would overwrite it, so it is ignored.
+	opts.BasicAuth = nil
+
+	provider := httpclient.NewProvider(httpclient.ProviderOptions{
+		Middlewares: append(httpclient.DefaultMiddlewares(), clientMiddlewares(s)...),
+	})
+	client, err := provider.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	return client, nil
+}
+
+// clientMiddlewares returns the plugin's own middlewares, run after the SDK
+// defaults (tracing, custom headers, contextual middleware) on every
+// outgoing request. This is the hook for transport-level concerns such as
+// metrics and record/replay; per-call logging happens in Client.get, which
+// knows the retry and cache outcome. Replay comes last so it sits closest
+// to the network.
+func clientMiddlewares(s Settings) []httpclient.Middleware {
+	mws := []httpclient.Middleware{
+		metricsMiddleware(),
+	}
+	if s.ReplayMode != replayOff {
+		mws = append(mws, replayMiddleware(s))
+	}
+	return mws
+}
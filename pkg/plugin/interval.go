@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// autoInterval is the QueryModel.Interval value (and the empty default)
+// that asks the plugin to pick the trend interval.
+const autoInterval = "auto"
+
+// defaultMaxDataPoints is assumed when Grafana sends no MaxDataPoints, e.g.
+// for alert queries.
+const defaultMaxDataPoints = 1000
+
+// rawStepEstimate is the assumed spacing of raw trend samples. It is only
+// used to decide whether raw data would fit in the panel.
+const rawStepEstimate = time.Minute
+
+// trendIntervals lists the fixed Novant trend intervals, finest first.
+var trendIntervals = []struct {
+	name string
+	step time.Duration
+}{
+	{"5min", 5 * time.Minute},
+	{"15min", 15 * time.Minute},
+	{"30min", 30 * time.Minute},
+	{"1hr", time.Hour},
+	{"1day", 24 * time.Hour},
+	{"1mo", 30 * 24 * time.Hour},
+}
+
+// intervalStep returns the nominal row spacing of a Novant interval, or
+// false for raw, auto, and unknown intervals.
+func intervalStep(interval string) (time.Duration, bool) {
+	for _, ti := range trendIntervals {
+		if ti.name == interval {
+			return ti.step, true
+		}
+	}
+	return 0, false
+}
+
+// resolveInterval returns the Novant interval to request for a trends
+// query, and whether it was picked automatically. An explicit interval is
+// passed through. For auto, the finest interval is chosen whose spacing is
+// at least Grafana's computed query interval and yields no more rows than
+// MaxDataPoints across the range, so a panel never downloads far more rows
+// than it has pixels. Raw is only chosen when even one-minute samples would
+// fit.
+func resolveInterval(q backend.DataQuery, requested string) (string, bool) {
+	if requested != "" && requested != autoInterval {
+		return requested, false
+	}
+
+	maxPoints := q.MaxDataPoints
+	if maxPoints <= 0 {
+		maxPoints = defaultMaxDataPoints
+	}
+	span := q.TimeRange.To.Sub(q.TimeRange.From)
+	target := span / time.Duration(maxPoints)
+	if q.Interval > target {
+		target = q.Interval
+	}
+
+	if target <= rawStepEstimate {
+		return "raw", true
+	}
+	for _, ti := range trendIntervals {
+		if ti.step >= target {
+			return ti.name, true
+		}
+	}
+	return trendIntervals[len(trendIntervals)-1].name, true
+}
@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+func TestResolveInterval(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		name      string
+		requested string
+		span      time.Duration
+		maxPoints int64
+		minStep   time.Duration // Grafana's computed query interval
+		want      string
+		auto      bool
+	}{
+		{"explicit", "15min", 365 * day, 1000, 0, "15min", false},
+		{"explicit raw", "raw", 365 * day, 1000, 0, "raw", false},
+		{"empty is auto", "", day, 1000, 0, "5min", true},
+		{"hour fits raw", autoInterval, time.Hour, 1000, 0, "raw", true},
+		{"one minute per point is raw", autoInterval, 1000 * time.Minute, 1000, 0, "raw", true},
+		{"exactly 5min", autoInterval, 5000 * time.Minute, 1000, 0, "5min", true},
+		{"day", autoInterval, day, 1000, 0, "5min", true},
+		{"narrow panel", autoInterval, day, 100, 0, "15min", true},
+		{"week", autoInterval, 7 * day, 1000, 0, "15min", true},
+		{"month", autoInterval, 30 * day, 1000, 0, "1hr", true},
+		{"year", autoInterval, 365 * day, 1000, 0, "1day", true},
+		{"decade caps at 1mo", autoInterval, 3650 * day, 100, 0, "1mo", true},
+		{"no max data points", autoInterval, day, 0, 0, "5min", true},
+		{"min interval floor", autoInterval, time.Hour, 1000, 10 * time.Minute, "15min", true},
+		{"min interval below target", autoInterval, 30 * day, 1000, time.Minute, "1hr", true},
+	}
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := backend.DataQuery{
+				TimeRange:     backend.TimeRange{From: from, To: from.Add(tt.span)},
+				MaxDataPoints: tt.maxPoints,
+				Interval:      tt.minStep,
+			}
+			got, auto := resolveInterval(q, tt.requested)
+			if got != tt.want || auto != tt.auto {
+				t.Errorf("resolveInterval = %q, %v; want %q, %v", got, auto, tt.want, tt.auto)
+			}
+		})
+	}
+}
+
+func TestIntervalStep(t *testing.T) {
+	tests := []struct {
+		interval string
+		step     time.Duration
+		ok       bool
+	}{
+		{"5min", 5 * time.Minute, true},
+		{"1hr", time.Hour, true},
+		{"1day", 24 * time.Hour, true},
+		{"raw", 0, false},
+		{autoInterval, 0, false},
+		{"2hr", 0, false},
+	}
+	for _, tt := range tests {
+		if step, ok := intervalStep(tt.interval); step != tt.step || ok != tt.ok {
+			t.Errorf("intervalStep(%q) = %v, %v; want %v, %v", tt.interval, step, ok, tt.step, tt.ok)
+		}
+	}
+}
+
+// The picked interval is requested from the API and reported on the frame
+// for the query inspector.
+func TestQueryTrendsAutoInterval(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, _ := newTestDatasource(t, b, nil)
+	loc, _ := time.LoadLocation(b.Tz)
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+
+	// The week crosses the spring DST change, so it is an hour short.
+	from, to := day, day.AddDate(0, 0, 7)
+	resp := runQuery(t, ds, "trends", map[string]interface{}{"pointIds": "s.1.1"}, from, to)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	meta := resp.Frames[0].Meta
+	if !strings.Contains(meta.ExecutedQueryString, "interval=15min (auto)") {
+		t.Errorf("executed query %q, want interval=15min (auto)", meta.ExecutedQueryString)
+	}
+	want := int(to.Sub(from)/(15*time.Minute)) + 1
+	if rows, _ := resp.Frames[0].RowLen(); rows != want {
+		t.Errorf("%d rows, want %d 15-minute rows", rows, want)
+	}
+}
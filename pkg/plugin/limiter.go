@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Limiter defaults. A large dashboard fires many /v1/values and /v1/trends
+// calls at once; these keep a single API key comfortably under the Novant
+// rate limit while still letting panels load in parallel.
+const (
+	defaultRateLimit   = 10.0 // requests per second
+	defaultRateBurst   = 10
+	defaultMaxInFlight = 8
+)
+
+// apiLimiter is a token-bucket rate limiter combined with a cap on
+// concurrent requests. One apiLimiter is shared by every Client using the
+// same API key (see sharedLimiter), since that is the unit the Novant API
+// rate-limits on.
+type apiLimiter struct {
+	mu sync.Mutex
+
+	rate   float64 // tokens per second; 0 disables rate limiting
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	maxInFlight int // 0 disables the concurrency cap
+	inFlight    int
+	freed       chan struct{} // closed and replaced whenever a slot frees
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*apiLimiter)
+)
+
+// sharedLimiter returns the limiter for apiKey, creating it if needed. The
+// limits are (re)applied from settings, so saving the data source updates
+// the limiter for every instance sharing the key. The key itself is only
+// used hashed.
+func sharedLimiter(apiKey string, settings Settings) *apiLimiter {
+	sum := sha256.Sum256([]byte(apiKey))
+	id := hex.EncodeToString(sum[:])
+
+	limitersMu.Lock()
+	l, ok := limiters[id]
+	if !ok {
+		l = &apiLimiter{freed: make(chan struct{})}
+		limiters[id] = l
+	}
+	limitersMu.Unlock()
+
+	l.configure(settings)
+	return l
+}
+
+// configure applies the limits from settings; zero values select defaults
+// and negative values disable the corresponding limit.
+func (l *apiLimiter) configure(s Settings) {
+	rate, burst, maxInFlight := defaultRateLimit, defaultRateBurst, defaultMaxInFlight
+	switch {
+	case s.RateLimit > 0:
+		rate = s.RateLimit
+	case s.RateLimit < 0:
+		rate = 0
+	}
+	if s.RateBurst > 0 {
+		burst = s.RateBurst
+	}
+	switch {
+	case s.MaxConcurrent > 0:
+		maxInFlight = s.MaxConcurrent
+	case s.MaxConcurrent < 0:
+		maxInFlight = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first := l.last.IsZero()
+	l.rate = rate
+	l.burst = float64(burst)
+	if first || l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = time.Now()
+	l.maxInFlight = maxInFlight
+	// Wake waiters in case the cap was raised.
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// acquire blocks until a concurrency slot and a rate token are available or
+// ctx is done. It returns how long the caller waited. On success the caller
+// must call release once the request completes.
+func (l *apiLimiter) acquire(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+
+	// Concurrency slot.
+	for {
+		l.mu.Lock()
+		if l.maxInFlight == 0 || l.inFlight < l.maxInFlight {
+			l.inFlight++
+			l.mu.Unlock()
+			break
+		}
+		freed := l.freed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return time.Since(start), ctx.Err()
+		case <-freed:
+		}
+	}
+
+	// Rate token. The token is reserved up front (tokens may go negative),
+	// so concurrent waiters queue behind each other instead of racing.
+	l.mu.Lock()
+	var wait time.Duration
+	if l.rate > 0 {
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			l.mu.Lock()
+			l.tokens++
+			l.mu.Unlock()
+			l.release()
+			return time.Since(start), ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return time.Since(start), nil
+}
+
+// release frees the concurrency slot taken by acquire.
+func (l *apiLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(s Settings) *apiLimiter {
+	l := &apiLimiter{freed: make(chan struct{})}
+	l.configure(s)
+	return l
+}
+
+func TestLimiterConfigure(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    Settings
+		rate        float64
+		burst       float64
+		maxInFlight int
+	}{
+		{"defaults", Settings{}, defaultRateLimit, defaultRateBurst, defaultMaxInFlight},
+		{"custom", Settings{RateLimit: 2.5, RateBurst: 4, MaxConcurrent: 3}, 2.5, 4, 3},
+		{"disabled", Settings{RateLimit: -1, MaxConcurrent: -1}, 0, defaultRateBurst, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(tt.settings)
+			if l.rate != tt.rate || l.burst != tt.burst || l.maxInFlight != tt.maxInFlight {
+				t.Errorf("rate, burst, maxInFlight = %v, %v, %d; want %v, %v, %d",
+					l.rate, l.burst, l.maxInFlight, tt.rate, tt.burst, tt.maxInFlight)
+			}
+			if l.tokens != tt.burst {
+				t.Errorf("tokens = %v, want a full bucket of %v", l.tokens, tt.burst)
+			}
+		})
+	}
+}
+
+// Every Client on the same key shares one limiter, and saving any of their
+// data sources re-applies the limits to it.
+func TestSharedLimiter(t *testing.T) {
+	key := "ak_" + t.Name()
+	a := sharedLimiter(key, Settings{RateLimit: 5})
+	b := sharedLimiter(key, Settings{RateLimit: 7})
+	if a != b {
+		t.Fatal("same key returned different limiters")
+	}
+	if a.rate != 7 {
+		t.Errorf("rate = %v, want the latest settings (7)", a.rate)
+	}
+	if other := sharedLimiter(key+"_other", Settings{}); other == a {
+		t.Error("different keys share a limiter")
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := newTestLimiter(Settings{RateLimit: 50, RateBurst: 2, MaxConcurrent: -1})
+	ctx := context.Background()
+
+	var waits []time.Duration
+	for i := 0; i < 3; i++ {
+		wait, err := l.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.release()
+		waits = append(waits, wait)
+	}
+	if waits[0] > 5*time.Millisecond || waits[1] > 5*time.Millisecond {
+		t.Errorf("burst waited %v, want no wait", waits[:2])
+	}
+	// The third request is past the burst and waits about one token (20ms).
+	if waits[2] < 10*time.Millisecond {
+		t.Errorf("third request waited %v, want about 20ms", waits[2])
+	}
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	l := newTestLimiter(Settings{RateLimit: -1, MaxConcurrent: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third acquire = %v, want it to block until the deadline", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(context.Background())
+		done <- err
+	}()
+	l.release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire not woken by release")
+	}
+}
+
+// A waiter cancelled while queued for a rate token gives the token and its
+// concurrency slot back.
+func TestLimiterCancelReturnsToken(t *testing.T) {
+	l := newTestLimiter(Settings{RateLimit: 1, RateBurst: 1, MaxConcurrent: 1})
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	l.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err == nil {
+		t.Fatal("acquire past the burst succeeded before its token")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight != 0 {
+		t.Errorf("inFlight = %d, want 0", l.inFlight)
+	}
+	if l.tokens < -0.1 {
+		t.Errorf("tokens = %v, want the reserved token returned", l.tokens)
+	}
+}
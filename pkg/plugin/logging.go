@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+)
+
+// logLevel is the minimum severity of plugin log lines for a data source.
+// It filters on top of Grafana's own log level, so debug lines need both the
+// data source and Grafana set to debug.
+type logLevel int
+
+const (
+	logDebug logLevel = iota
+	logInfo
+	logWarn
+	logError
+)
+
+// maxLoggedValue caps logged parameter values and error bodies.
+const maxLoggedValue = 256
+
+// parseLogLevel parses the logLevel data source setting. Empty selects info.
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return logDebug, nil
+	case "", "info":
+		return logInfo, nil
+	case "warn", "warning":
+		return logWarn, nil
+	case "error":
+		return logError, nil
+	}
+	return logInfo, fmt.Errorf("invalid logLevel %q: must be debug, info, warn, or error", s)
+}
+
+// requestLogger is a leveled logger bound to one Grafana request. Every line
+// carries the request's correlation ID along with the SDK's contextual
+// attributes (plugin, data source, user, trace ID).
+type requestLogger struct {
+	log           log.Logger
+	level         logLevel
+	correlationID string
+}
+
+func (l *requestLogger) Debug(msg string, args ...interface{}) {
+	if l.level <= logDebug {
+		l.log.Debug(msg, args...)
+	}
+}
+
+func (l *requestLogger) Info(msg string, args ...interface{}) {
+	if l.level <= logInfo {
+		l.log.Info(msg, args...)
+	}
+}
+
+func (l *requestLogger) Warn(msg string, args ...interface{}) {
+	if l.level <= logWarn {
+		l.log.Warn(msg, args...)
+	}
+}
+
+func (l *requestLogger) Error(msg string, args ...interface{}) {
+	l.log.Error(msg, args...)
+}
+
+type requestLoggerKey struct{}
+
+// withRequestLogger returns a child context carrying a requestLogger for a
+// new Grafana request. The trace ID is reused as the correlation ID when
+// tracing is active so logs and traces line up. The correlation ID is also
+// sent to Novant as X-Request-Id.
+func withRequestLogger(ctx context.Context, level logLevel) context.Context {
+	id := tracing.TraceIDFromContext(ctx, false)
+	if id == "" {
+		id = newCorrelationID()
+	}
+	l := &requestLogger{
+		log:           backend.Logger.FromContext(ctx).With("correlationId", id),
+		level:         level,
+		correlationID: id,
+	}
+	return context.WithValue(ctx, requestLoggerKey{}, l)
+}
+
+// correlationIDFromContext returns the correlation ID of the request on
+// ctx, or "" outside a logged request.
+func correlationIDFromContext(ctx context.Context) string {
+	if l, ok := ctx.Value(requestLoggerKey{}).(*requestLogger); ok {
+		return l.correlationID
+	}
+	return ""
+}
+
+// loggerFromContext returns the requestLogger on ctx, or a logger at info
+// level without a correlation ID.
+func loggerFromContext(ctx context.Context) *requestLogger {
+	if l, ok := ctx.Value(requestLoggerKey{}).(*requestLogger); ok {
+		return l
+	}
+	return &requestLogger{log: backend.Logger.FromContext(ctx), level: logInfo}
+}
+
+func newCorrelationID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// apiKeyPattern matches Novant API keys so they can be scrubbed from
+// anything that is logged or echoed back in an error.
+var apiKeyPattern = regexp.MustCompile(`ak_[A-Za-z0-9_\-]+`)
+
+// redact removes API keys from s and truncates it to maxLoggedValue.
+func redact(s string) string {
+	s = apiKeyPattern.ReplaceAllString(s, "ak_[redacted]")
+	return truncate(s, maxLoggedValue)
+}
+
+// truncate shortens s to at most n bytes, marking the cut.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…(truncated)"
+}
+
+// redactParams renders query parameters for logging with every value
+// passed through redact.
+func redactParams(params url.Values) string {
+	if len(params) == 0 {
+		return ""
+	}
+	safe := make(url.Values, len(params))
+	for k, vs := range params {
+		for _, v := range vs {
+			safe.Add(k, redact(v))
+		}
+	}
+	s, _ := url.QueryUnescape(safe.Encode())
+	return s
+}
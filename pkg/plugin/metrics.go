@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Plugin metrics are registered with the default Prometheus registry, which
+// the SDK serves through Grafana's plugin metrics endpoint
+// (/api/plugins/novant-datasource/metrics).
+const metricsNamespace = "novant_datasource"
+
+var (
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_requests_total",
+		Help:      "Novant API requests by endpoint and HTTP status (\"error\" for transport failures).",
+	}, []string{"endpoint", "status"})
+
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Novant API request latency by endpoint and HTTP status, up to response headers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+
+	apiResponseBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_response_bytes",
+		Help:      "Novant API response body size on the wire (before gzip decoding) by endpoint.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"endpoint"})
+
+	apiRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_retries_total",
+		Help:      "Novant API requests retried after a transient failure, by endpoint.",
+	}, []string{"endpoint"})
+
+	rateLimitWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rate_limit_wait_seconds",
+		Help:      "Time requests spent waiting on the client-side rate limiter and concurrency cap.",
+		Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache (points, values) and result (hit, miss).",
+	}, []string{"cache", "result"})
+
+	cacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_evictions_total",
+		Help:      "Cache entries dropped because they expired or the cache was cleared, by cache.",
+	}, []string{"cache"})
+
+	coalescedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "coalesced_requests_total",
+		Help:      "API fetches served by joining an identical in-flight request instead of issuing a new one.",
+	}, []string{"cache"})
+)
+
+// recordCacheLookup counts a cache hit or miss.
+func recordCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// metricsMiddleware records request count, latency, and response size for
+// every Novant API call. Endpoints are labelled by URL path, which is a
+// small fixed set (/v1/points, /v1/trends, ...).
+func metricsMiddleware() httpclient.Middleware {
+	return httpclient.NamedMiddlewareFunc("novant-metrics", func(_ httpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			endpoint := req.URL.Path
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start).Seconds()
+			if err != nil {
+				apiRequests.WithLabelValues(endpoint, "error").Inc()
+				apiRequestDuration.WithLabelValues(endpoint, "error").Observe(elapsed)
+				return resp, err
+			}
+
+			status := strconv.Itoa(resp.StatusCode)
+			apiRequests.WithLabelValues(endpoint, status).Inc()
+			apiRequestDuration.WithLabelValues(endpoint, status).Observe(elapsed)
+			if resp.Body != nil {
+				resp.Body = httpclient.CountBytesReader(resp.Body, func(n int64) {
+					apiResponseBytes.WithLabelValues(endpoint).Observe(float64(n))
+				})
+			}
+			return resp, nil
+		})
+	})
+}
@@ -3,6 +3,7 @@ package plugin
 import (
 	"encoding/json"
 	"strings"
+	"time"
 )
 
 // FlexString unmarshals a JSON string, number, bool, or null into a string.
@@ -31,6 +32,9 @@ func (s *FlexString) UnmarshalJSON(data []byte) error {
 // QueryModel is the frontend query deserialized from JSON.
 // The queryType is read from backend.DataQuery.QueryType (the top-level SDK field), not from here.
 type QueryModel struct {
+	// Project selects the project(s) to query: "" for the first configured
+	// project, a comma-separated list of project names, or "*" for all.
+	Project string `json:"project"`
 	// Entity filters
 	ZoneIDs   string `json:"zoneIds"`
 	SpaceIDs  string `json:"spaceIds"`
@@ -47,6 +51,10 @@ type QueryModel struct {
 	// Trend options
 	Interval  string `json:"interval"`
 	Aggregate string `json:"aggregate"`
+	// Format shapes trend frames: wide (default), long, multi, or alert.
+	Format string `json:"format"`
+	// CoerceBools emits boolean trend points as numeric 0/1.
+	CoerceBools bool `json:"coerceBools"`
 }
 
 // Novant API response types
@@ -144,17 +152,21 @@ type ValuesResp struct {
 	Values   []PointValue `json:"values"`
 }
 
-type TrendRow struct {
-	Ts     string                 `json:"ts"`
-	Values map[string]interface{} `json:"-"`
-}
-
+// TrendsResp is a decoded /v1/trends response. The API returns one row per
+// timestamp with a dynamic key per point ID; decodeTrends transposes these
+// into one TrendSeries per point, aligned with Ts.
 type TrendsResp struct {
-	Start     string     `json:"start"`
-	End       string     `json:"end"`
-	Tz        string     `json:"tz"`
-	Interval  string     `json:"interval"`
-	Aggregate string     `json:"aggregate"`
-	PointIDs  []string   `json:"point_ids"`
-	Trends    []TrendRow `json:"trends"`
+	Start     string   `json:"start"`
+	End       string   `json:"end"`
+	Tz        string   `json:"tz"`
+	Interval  string   `json:"interval"`
+	Aggregate string   `json:"aggregate"`
+	PointIDs  []string `json:"point_ids"`
+	// Ts holds the raw row timestamps.
+	Ts []string `json:"-"`
+	// Times holds Ts parsed in the project's time zone once resolveTimes
+	// has run.
+	Times []time.Time `json:"-"`
+	// Series maps point ID to its samples. Every series has len(Ts) rows.
+	Series map[string]*TrendSeries `json:"-"`
 }
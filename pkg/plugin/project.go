@@ -0,0 +1,233 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultProjectName names the project configured through the original
+// single apiKey secure field.
+const defaultProjectName = "default"
+
+// allProjects is the QueryModel.Project value that fans a query out across
+// every configured project.
+const allProjects = "*"
+
+// project is one Novant project (one ak_ key) behind the data source. Point
+// IDs are only unique within a project, so each project has its own client
+// and caches.
+type project struct {
+	name       string
+	client     *Client
+	pointCache *pointCache
+	valueCache *valueCache
+	trendCache *trendCache // shared by all projects of the data source
+	// entityCache backs the labels of alert-format trends.
+	entityCache *entityCache
+
+	infoMu sync.Mutex
+	info   *ProjectResp
+}
+
+func newProject(name, apiKey string, settings Settings, httpClient *http.Client, trends *trendCache) *project {
+	client := NewClient(apiKey, settings, httpClient)
+	client.project = name
+	return &project{
+		name:        name,
+		client:      client,
+		pointCache:  newPointCache(),
+		valueCache:  newValueCache(),
+		trendCache:  trends,
+		entityCache: newEntityCache(),
+	}
+}
+
+// projectKeys returns the configured projects in order as name → API key
+// pairs. The legacy apiKey field becomes the "default" project; additional
+// projects are listed by name in jsonData.projects and keep their key in
+// the "apiKey.<name>" secure field.
+func projectKeys(settings Settings, secure map[string]string) ([][2]string, error) {
+	var keys [][2]string
+	if k := secure["apiKey"]; k != "" {
+		keys = append(keys, [2]string{defaultProjectName, k})
+	}
+
+	seen := map[string]bool{defaultProjectName: keys != nil}
+	for _, ps := range settings.Projects {
+		name := strings.TrimSpace(ps.Name)
+		if name == "" || name == allProjects || strings.Contains(name, ",") {
+			return nil, fmt.Errorf("invalid project name %q", ps.Name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate project name %q", name)
+		}
+		seen[name] = true
+		k := secure["apiKey."+name]
+		if k == "" {
+			return nil, fmt.Errorf("API key is required for project %q", name)
+		}
+		keys = append(keys, [2]string{name, k})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("API key is required")
+	}
+	return keys, nil
+}
+
+// projectInfo returns /v1/project for the project, fetched once and kept
+// for the life of the data source instance.
+func (p *project) projectInfo(ctx context.Context) (*ProjectResp, error) {
+	p.infoMu.Lock()
+	defer p.infoMu.Unlock()
+	if p.info != nil {
+		return p.info, nil
+	}
+	info, err := p.client.GetProject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.info = info
+	return info, nil
+}
+
+// label returns the Novant project name used to tag frames, falling back to
+// the configured name if /v1/project cannot be fetched.
+func (p *project) label(ctx context.Context) string {
+	if info, err := p.projectInfo(ctx); err == nil && info.ProjName != "" {
+		return info.ProjName
+	}
+	return p.name
+}
+
+// location returns the project's time zone from /v1/project; a project
+// without one is in UTC. If the zone cannot be fetched or loaded, UTC is
+// returned along with the error, so a query can still be served, but its
+// days are not the project's and must not be cached. Nothing is kept on
+// failure, so the next call tries /v1/project again.
+func (p *project) location(ctx context.Context) (*time.Location, error) {
+	info, err := p.projectInfo(ctx)
+	if err != nil {
+		return time.UTC, fmt.Errorf("fetching project time zone: %w", err)
+	}
+	if info.Tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(info.Tz)
+	if err != nil {
+		return time.UTC, fmt.Errorf("loading project time zone: %w", err)
+	}
+	return loc, nil
+}
+
+// clear drops every cache held for the project.
+func (p *project) clear() {
+	p.pointCache.clear()
+	p.valueCache.clear()
+	p.entityCache.clear()
+	p.trendCache.clearProject(p.name)
+	p.client.ClearValidators()
+}
+
+// selectProjects resolves the QueryModel.Project value: "" selects the
+// first configured project, "*" selects all of them, and anything else is
+// a comma-separated list of project names.
+func (d *Datasource) selectProjects(name string) ([]*project, error) {
+	name = strings.TrimSpace(name)
+	switch name {
+	case "":
+		return d.projects[:1], nil
+	case allProjects:
+		return d.projects, nil
+	}
+
+	var out []*project
+	for _, n := range strings.Split(name, ",") {
+		n = strings.TrimSpace(n)
+		p, ok := d.projectsByName[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown project %q", n)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// tagProject marks frames with the project they came from: fields of frames
+// with a time field get a "project" label, and table frames get a leading
+// "project" column so merged tables stay distinguishable. Long time series
+// carry their dimensions as columns, so they get a "project" column after
+// the time field. Trends may hold bool and string fields, so a time field
+// rather than the SDK's numeric time series schema decides which is which.
+func tagProject(frames data.Frames, projName string) {
+	for _, f := range frames {
+		if f.Meta != nil && f.Meta.Type == data.FrameTypeTimeSeriesLong {
+			rows, _ := f.RowLen()
+			col := make([]string, rows)
+			for i := range col {
+				col[i] = projName
+			}
+			f.Fields = append(f.Fields[:1], append([]*data.Field{data.NewField("project", nil, col)}, f.Fields[1:]...)...)
+			continue
+		}
+		if len(f.TypeIndices(data.FieldTypeTime, data.FieldTypeNullableTime)) > 0 {
+			for _, field := range f.Fields {
+				if field.Type().Time() {
+					continue
+				}
+				if field.Labels == nil {
+					field.Labels = data.Labels{}
+				}
+				field.Labels["project"] = projName
+			}
+			continue
+		}
+
+		rows, _ := f.RowLen()
+		col := make([]string, rows)
+		for i := range col {
+			col[i] = projName
+		}
+		f.Fields = append([]*data.Field{data.NewField("project", nil, col)}, f.Fields...)
+	}
+}
+
+// mergeProjectResponses combines per-project responses from a fanned-out
+// query. Projects that failed are reported as a warning notice as long as
+// at least one project succeeded; if all failed, the first error is
+// returned.
+func mergeProjectResponses(projects []*project, resps []backend.DataResponse) backend.DataResponse {
+	var merged backend.DataResponse
+	var failed []string
+	var firstErr *backend.DataResponse
+	for i, r := range resps {
+		if r.Error != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", projects[i].name, r.Error))
+			if firstErr == nil {
+				firstErr = &resps[i]
+			}
+			continue
+		}
+		merged.Frames = append(merged.Frames, r.Frames...)
+	}
+	if len(failed) == len(resps) {
+		return *firstErr
+	}
+	if len(failed) > 0 {
+		if len(merged.Frames) == 0 {
+			merged.Frames = data.Frames{data.NewFrame("")}
+		}
+		merged.Frames[0].AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "Some projects failed: " + strings.Join(failed, "; "),
+		})
+	}
+	return merged
+}
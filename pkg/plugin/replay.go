@@ -0,0 +1,284 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+// Record/replay modes for Novant API traffic, used to reproduce bug reports
+// offline: a customer (or support) records the failing dashboard's traffic,
+// and a developer replays it without a key or network access.
+const (
+	replayOff    = ""
+	replayRecord = "record"
+	replayReplay = "replay"
+)
+
+// Environment variables that override the replayMode and replayDir data
+// source settings, so a local Grafana can be switched without editing the
+// data source.
+const (
+	replayModeEnv = "NOVANT_REPLAY_MODE"
+	replayDirEnv  = "NOVANT_REPLAY_DIR"
+)
+
+// applyReplayEnv overrides the replay settings from the environment and
+// validates the result. Empty variables are ignored, so a compose file that
+// always passes them through leaves the data source settings in effect.
+// NOVANT_REPLAY_MODE=off turns replay off regardless of the settings.
+func applyReplayEnv(s *Settings) error {
+	if v := os.Getenv(replayModeEnv); v != "" {
+		s.ReplayMode = v
+	}
+	if v := os.Getenv(replayDirEnv); v != "" {
+		s.ReplayDir = v
+	}
+
+	s.ReplayMode = strings.ToLower(strings.TrimSpace(s.ReplayMode))
+	switch s.ReplayMode {
+	case "off":
+		s.ReplayMode = replayOff
+	case replayOff, replayRecord, replayReplay:
+	default:
+		return fmt.Errorf("invalid replayMode %q: must be off, record, or replay", s.ReplayMode)
+	}
+	if s.ReplayMode != replayOff && strings.TrimSpace(s.ReplayDir) == "" {
+		return fmt.Errorf("replayDir is required for replayMode %q", s.ReplayMode)
+	}
+	return nil
+}
+
+// fixture is one recorded request/response pair, stored as a JSON file.
+// Request headers are not recorded, so the API key (sent as basic auth)
+// never reaches disk; keys anywhere in the URL or body are redacted.
+type fixture struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"` // path and query, without host
+	Response fixtureResponse `json:"response"`
+}
+
+type fixtureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// fixtureHeaders are the response headers worth replaying.
+var fixtureHeaders = []string{"Content-Type", "Retry-After"}
+
+// fixtureRequestURL returns the redacted path and query that identify a
+// request. Query parameters are re-encoded in sorted order so equivalent
+// requests match regardless of parameter order.
+func fixtureRequestURL(req *http.Request) string {
+	u := apiKeyPattern.ReplaceAllString(req.URL.Path, "ak_[redacted]")
+	if q := req.URL.Query(); len(q) > 0 {
+		u += "?" + apiKeyPattern.ReplaceAllString(q.Encode(), "ak_[redacted]")
+	}
+	return u
+}
+
+type fixtureProjectKey struct{}
+
+// withFixtureProject tags ctx with the data source project a request is made
+// for. Projects share one HTTP client, so the replay middleware reads the
+// project from the request context.
+func withFixtureProject(ctx context.Context, project string) context.Context {
+	if project == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, fixtureProjectKey{}, project)
+}
+
+// fixtureProjectDir returns the fixture subdirectory for the request's
+// project. The same request from two projects returns different buildings,
+// so each project records into its own directory, named after the project
+// with anything but letters, digits, '-', '_' and '.' replaced by '_'.
+func fixtureProjectDir(req *http.Request) string {
+	project, _ := req.Context().Value(fixtureProjectKey{}).(string)
+	if project == "" {
+		project = defaultProjectName
+	}
+	name := []byte(project)
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			name[i] = '_'
+		}
+	}
+	if s := string(name); s != "." && s != ".." {
+		return s
+	}
+	return "_"
+}
+
+// fixtureFile returns the fixture path for a request: the project's
+// subdirectory, then a readable prefix from the API path plus a hash of the
+// method and URL.
+func fixtureFile(dir string, req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + fixtureRequestURL(req)))
+	name := strings.Trim(strings.ReplaceAll(req.URL.Path, "/", "_"), "_")
+	return filepath.Join(dir, fixtureProjectDir(req), fmt.Sprintf("%s_%s_%s.json", req.Method, name, hex.EncodeToString(sum[:6])))
+}
+
+// replayMiddleware records or replays API traffic according to the replay
+// settings. It runs innermost, so replayed requests never reach the network.
+// Each project of a multi-project data source has its own fixture
+// subdirectory (see fixtureFile).
+func replayMiddleware(settings Settings) httpclient.Middleware {
+	mode, dir := settings.ReplayMode, settings.ReplayDir
+	return httpclient.NamedMiddlewareFunc("novant-replay", func(_ httpclient.Options, next http.RoundTripper) http.RoundTripper {
+		switch mode {
+		case replayRecord:
+			return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return recordRoundTrip(next, dir, req)
+			})
+		case replayReplay:
+			return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return replayRoundTrip(dir, req)
+			})
+		}
+		return next
+	})
+}
+
+// recordRoundTrip sends req and saves the response as a fixture. Bodies are
+// stored decompressed, and conditional headers are dropped from the request
+// so every fixture holds a full body rather than a 304.
+func recordRoundTrip(next http.RoundTripper, dir string, req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("record: creating gzip reader: %w", err)
+		}
+		defer gr.Close()
+		body = gr
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("record: reading response: %w", err)
+	}
+
+	fx := fixture{
+		Method: req.Method,
+		URL:    fixtureRequestURL(req),
+		Response: fixtureResponse{
+			Status:  resp.StatusCode,
+			Headers: make(map[string]string),
+			Body:    apiKeyPattern.ReplaceAllString(string(raw), "ak_[redacted]"),
+		},
+	}
+	for _, h := range fixtureHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			fx.Response.Headers[h] = v
+		}
+	}
+	if err := writeFixture(fixtureFile(dir, req), fx); err != nil {
+		loggerFromContext(req.Context()).Warn("Failed to record Novant API fixture",
+			"endpoint", req.URL.Path, "error", err)
+	}
+
+	// Hand the client the plain body it would have decoded anyway.
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	resp.ContentLength = int64(len(raw))
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	return resp, nil
+}
+
+// writeFixture writes fx atomically so concurrent recordings of the same
+// request never leave a partial file.
+func writeFixture(path string, fx fixture) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fixture-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fixtureError reports a request that has no fixture in replay mode. It is
+// never retried.
+type fixtureError struct {
+	method, url, file string
+}
+
+func (e *fixtureError) Error() string {
+	return fmt.Sprintf("replay: no fixture for %s %s (expected %s)", e.method, e.url, e.file)
+}
+
+// replayRoundTrip serves req from its fixture. A request with no fixture
+// fails with an error naming the request and the file that was expected,
+// and is logged so every gap in a recording can be found in one pass.
+func replayRoundTrip(dir string, req *http.Request) (*http.Response, error) {
+	path := fixtureFile(dir, req)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		reqURL := fixtureRequestURL(req)
+		loggerFromContext(req.Context()).Warn("Unmatched Novant API request in replay mode",
+			"method", req.Method, "url", redact(reqURL), "fixture", path)
+		return nil, &fixtureError{method: req.Method, url: redact(reqURL), file: path}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("replay: reading fixture: %w", err)
+	}
+
+	var fx fixture
+	if err := json.Unmarshal(b, &fx); err != nil {
+		return nil, fmt.Errorf("replay: decoding fixture %s: %w", path, err)
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", fx.Response.Status, http.StatusText(fx.Response.Status)),
+		StatusCode:    fx.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(fx.Response.Body)),
+		ContentLength: int64(len(fx.Response.Body)),
+		Request:       req,
+	}
+	for k, v := range fx.Response.Headers {
+		resp.Header.Set(k, v)
+	}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyReplayEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		envMode      string
+		envDir       string
+		settingsMode string
+		settingsDir  string
+		wantMode     string
+		wantDir      string
+	}{
+		{"settings only", "", "", "record", "/tmp/fx", replayRecord, "/tmp/fx"},
+		{"empty env keeps settings", "", "/env", "replay", "", replayReplay, "/env"},
+		{"env overrides", "replay", "/env", "record", "/tmp/fx", replayReplay, "/env"},
+		{"env turns off", "off", "", "record", "/tmp/fx", replayOff, "/tmp/fx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(replayModeEnv, tt.envMode)
+			t.Setenv(replayDirEnv, tt.envDir)
+			s := Settings{ReplayMode: tt.settingsMode, ReplayDir: tt.settingsDir}
+			if err := applyReplayEnv(&s); err != nil {
+				t.Fatal(err)
+			}
+			if s.ReplayMode != tt.wantMode || s.ReplayDir != tt.wantDir {
+				t.Errorf("got mode %q dir %q, want %q %q", s.ReplayMode, s.ReplayDir, tt.wantMode, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestFixtureFilePerProject(t *testing.T) {
+	file := func(project string) string {
+		req, err := http.NewRequestWithContext(withFixtureProject(context.Background(), project),
+			http.MethodGet, "https://api.novant.io/v1/points?source_id=s.1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fixtureFile("/fx", req)
+	}
+
+	def, campus, other := file(""), file("campus"), file("../up")
+	if filepath.Dir(def) != "/fx/default" {
+		t.Errorf("default project fixture %s, want it under /fx/default", def)
+	}
+	if filepath.Dir(campus) != "/fx/campus" {
+		t.Errorf("campus project fixture %s, want it under /fx/campus", campus)
+	}
+	if filepath.Dir(other) != "/fx/.._up" {
+		t.Errorf("project %q fixture %s, want it under /fx/.._up", "../up", other)
+	}
+	if filepath.Base(def) != filepath.Base(campus) {
+		t.Errorf("same request named %s and %s", filepath.Base(def), filepath.Base(campus))
+	}
+}
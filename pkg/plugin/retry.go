@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry defaults. Novant GETs are idempotent, so a transient 5xx or 429 is
+// retried a few times before the panel is marked as failed.
+const (
+	defaultRetryMaxAttempts = 4
+	defaultRetryBudget      = 30 * time.Second
+	retryInitialBackoff     = 500 * time.Millisecond
+	retryMaxBackoff         = 10 * time.Second
+)
+
+// retryPolicy controls how Client.get retries failed requests.
+type retryPolicy struct {
+	// maxAttempts is the total number of attempts, including the first.
+	// A value of 1 disables retries.
+	maxAttempts int
+	// budget caps the total time spent waiting between attempts. A retry
+	// whose wait would exceed the budget is not attempted.
+	budget time.Duration
+}
+
+func newRetryPolicy(s Settings) retryPolicy {
+	p := retryPolicy{maxAttempts: defaultRetryMaxAttempts, budget: defaultRetryBudget}
+	if s.RetryMaxAttempts > 0 {
+		p.maxAttempts = s.RetryMaxAttempts
+	}
+	if s.RetryBudgetSeconds > 0 {
+		p.budget = time.Duration(s.RetryBudgetSeconds) * time.Second
+	}
+	return p
+}
+
+type noRetryKey struct{}
+
+// withoutRetries returns a context on which Client requests are attempted
+// once. The health check uses it so a bad base URL or key fails Save & test
+// straight away instead of after the whole retry budget.
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// do calls attempt until it succeeds, returns a non-retryable error, or the
+// attempt count, time budget, or ctx is exhausted. The last error is returned.
+// endpoint labels the retry metrics.
+func (p retryPolicy) do(ctx context.Context, endpoint string, attempt func() error) error {
+	start := time.Now()
+	maxAttempts := p.maxAttempts
+	if ctx.Value(noRetryKey{}) != nil {
+		maxAttempts = 1
+	}
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= maxAttempts || !retryable(ctx, err) {
+			return err
+		}
+
+		wait := backoff(n)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		if time.Since(start)+wait > p.budget {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		apiRetries.WithLabelValues(endpoint).Inc()
+	}
+}
+
+// backoff returns the wait before attempt n+1: exponential growth from
+// retryInitialBackoff, capped at retryMaxBackoff, with full jitter so
+// concurrent panels don't retry in lockstep.
+func backoff(n int) time.Duration {
+	d := retryInitialBackoff << (n - 1)
+	if d <= 0 || d > retryMaxBackoff {
+		d = retryMaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// retryable reports whether err is worth another attempt: rate limiting,
+// transient gateway or server failures, and transient network errors (see
+// transientNetError). Nothing is retried once ctx is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return transientNetError(err)
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay seconds
+// or as an HTTP date. It returns zero if the header is absent or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// urlErr wraps err the way http.Client.Do reports transport failures.
+func urlErr(err error) error {
+	return &url.Error{Op: "Get", URL: "https://api.novant.io/v1/zones", Err: err}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	dial := func(err error) error { return urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: err}) }
+	read := func(err error) error {
+		return urlErr(&net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: err}})
+	}
+	tlsDial := func(err error) error { return urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: err}) }
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"500", &APIError{StatusCode: http.StatusInternalServerError}, true},
+		{"502", &APIError{StatusCode: http.StatusBadGateway}, true},
+		{"503", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"504", &APIError{StatusCode: http.StatusGatewayTimeout}, true},
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, false},
+		{"401", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"404", &APIError{StatusCode: http.StatusNotFound}, false},
+		{"501", &APIError{StatusCode: http.StatusNotImplemented}, false},
+		{"timeout", urlErr(timeoutErr{}), true},
+		{"connection refused", dial(&os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}), true},
+		{"connection reset", read(syscall.ECONNRESET), true},
+		{"dns", dial(&net.DNSError{Err: "no such host", Name: "api.novant.io"}), true},
+		{"unknown authority", urlErr(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), false},
+		{"bare unknown authority", urlErr(x509.UnknownAuthorityError{}), false},
+		{"expired cert", urlErr(x509.CertificateInvalidError{Reason: x509.Expired}), false},
+		{"hostname mismatch", urlErr(x509.HostnameError{Host: "api.novant.io"}), false},
+		{"tls alert", tlsDial(tls.AlertError(40)), false},
+		{"tls record header", urlErr(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), false},
+		{"proxy auth", urlErr(&net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("Proxy Authentication Required")}), false},
+		{"proxy refused", urlErr(&net.OpError{Op: "proxyconnect", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}), true},
+		{"eof", urlErr(errors.New("EOF")), false},
+		{"fixture", &fixtureError{method: "GET", url: "/v1/zones", file: "x.json"}, false},
+		{"plain", errors.New("decode trends: unexpected token"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(context.Background(), tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("done context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if retryable(ctx, &APIError{StatusCode: http.StatusServiceUnavailable}) {
+			t.Error("retryable after ctx is done")
+		}
+	})
+}
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status backend.Status
+		source backend.ErrorSource // empty is the plugin default
+	}{
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, backend.StatusBadRequest, backend.ErrorSourceDownstream},
+		{"401", &APIError{StatusCode: http.StatusUnauthorized}, backend.StatusUnauthorized, backend.ErrorSourceDownstream},
+		{"429", &APIError{StatusCode: http.StatusTooManyRequests}, backend.StatusTooManyRequests, backend.ErrorSourceDownstream},
+		{"503", &APIError{StatusCode: http.StatusServiceUnavailable}, backend.StatusBadGateway, backend.ErrorSourceDownstream},
+		{"deadline", fmt.Errorf("get trends: %w", context.DeadlineExceeded), backend.StatusTimeout, ""},
+		{"timeout", urlErr(timeoutErr{}), backend.StatusBadGateway, backend.ErrorSourceDownstream},
+		{"connection refused", urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), backend.StatusBadGateway, backend.ErrorSourceDownstream},
+		{"unknown authority", urlErr(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), backend.StatusBadRequest, ""},
+		{"proxy auth", urlErr(&net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("Proxy Authentication Required")}), backend.StatusBadRequest, ""},
+		{"fixture", &fixtureError{method: "GET", url: "/v1/zones", file: "x.json"}, backend.StatusNotFound, ""},
+		{"plain", errors.New("boom"), backend.StatusInternal, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := errorResponse(tt.err)
+			if resp.Status != tt.status {
+				t.Errorf("status = %d, want %d", resp.Status, tt.status)
+			}
+			if resp.ErrorSource != tt.source {
+				t.Errorf("source = %q, want %q", resp.ErrorSource, tt.source)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		n   int
+		max time.Duration
+	}{
+		{1, retryInitialBackoff},
+		{2, 2 * retryInitialBackoff},
+		{3, 4 * retryInitialBackoff},
+		{6, retryMaxBackoff},
+		{64, retryMaxBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := backoff(tt.n); d <= 0 || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want in (0, %v]", tt.n, d, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	unavailable := &APIError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Millisecond}
+	tests := []struct {
+		name     string
+		policy   retryPolicy
+		ctx      context.Context
+		errs     []error // returned by successive attempts; nil after the list
+		attempts int
+		fail     bool
+	}{
+		{"success", retryPolicy{maxAttempts: 4, budget: time.Second}, context.Background(),
+			nil, 1, false},
+		{"recovers", retryPolicy{maxAttempts: 4, budget: time.Second}, context.Background(),
+			[]error{unavailable, unavailable}, 3, false},
+		{"max attempts", retryPolicy{maxAttempts: 3, budget: time.Second}, context.Background(),
+			[]error{unavailable, unavailable, unavailable, unavailable}, 3, true},
+		{"not retryable", retryPolicy{maxAttempts: 4, budget: time.Second}, context.Background(),
+			[]error{&APIError{StatusCode: http.StatusBadRequest}}, 1, true},
+		{"budget", retryPolicy{maxAttempts: 4, budget: time.Millisecond}, context.Background(),
+			[]error{&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}}, 1, true},
+		{"without retries", retryPolicy{maxAttempts: 4, budget: time.Second}, withoutRetries(context.Background()),
+			[]error{unavailable}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := 0
+			err := tt.policy.do(tt.ctx, "test", func() error {
+				n++
+				if n <= len(tt.errs) {
+					return tt.errs[n-1]
+				}
+				return nil
+			})
+			if n != tt.attempts {
+				t.Errorf("%d attempts, want %d", n, tt.attempts)
+			}
+			if (err != nil) != tt.fail {
+				t.Errorf("err = %v, want failure %v", err, tt.fail)
+			}
+		})
+	}
+}
+
+// A server whose certificate isn't trusted fails on the first attempt and
+// is reported as a data source configuration problem.
+func TestClientUntrustedCertNotRetried(t *testing.T) {
+	var handshakes int32
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		atomic.AddInt32(&handshakes, 1)
+		return nil, nil
+	}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	c := NewClient(testAPIKey, Settings{BaseURL: srv.URL}, nil)
+	_, err := c.GetZones(context.Background(), "")
+	if err == nil {
+		t.Fatal("expected a certificate error")
+	}
+	if n := atomic.LoadInt32(&handshakes); n != 1 {
+		t.Errorf("%d handshakes, want 1", n)
+	}
+	if resp := errorResponse(err); resp.Status != backend.StatusBadRequest {
+		t.Errorf("status = %d, want %d (%v)", resp.Status, backend.StatusBadRequest, err)
+	}
+}
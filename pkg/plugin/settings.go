@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// defaultBaseURL is the production Novant API endpoint, used when the data
+// source does not configure its own base URL.
+const defaultBaseURL = "https://api.novant.io"
+
+// Settings is the non-secret data source configuration stored in jsonData.
+// Every field is optional; zero values fall back to the plugin defaults.
+type Settings struct {
+	// BaseURL overrides the Novant API endpoint, e.g. a regional or staging
+	// API, or a local stand-in server for development and CI.
+	BaseURL string `json:"baseUrl"`
+	// RetryMaxAttempts is the total number of attempts for a failed GET,
+	// including the first. Set to 1 to disable retries.
+	RetryMaxAttempts int `json:"retryMaxAttempts"`
+	// RetryBudgetSeconds caps the total time spent waiting between retries.
+	RetryBudgetSeconds int `json:"retryBudgetSeconds"`
+	// RateLimit is the sustained request rate (per second) allowed for the
+	// API key, and RateBurst the bucket size. A negative RateLimit disables
+	// rate limiting.
+	RateLimit float64 `json:"rateLimit"`
+	RateBurst int     `json:"rateBurst"`
+	// MaxConcurrent caps in-flight requests for the API key. A negative
+	// value disables the cap.
+	MaxConcurrent int `json:"maxConcurrent"`
+	// PointBatchSize is the most point IDs sent in one /v1/values or
+	// /v1/trends request; longer lists are split and fetched concurrently.
+	PointBatchSize int `json:"pointBatchSize"`
+	// TrendCacheMB is the memory budget of the trends cache in MiB. A
+	// negative value disables the cache.
+	TrendCacheMB int `json:"trendCacheMB"`
+	// Projects lists additional Novant projects served by this data source
+	// alongside the one keyed by apiKey.
+	Projects []ProjectSettings `json:"projects"`
+	// ReplayMode records Novant API traffic to ReplayDir ("record") or
+	// serves it from there without network access ("replay"). Both can be
+	// overridden by a non-empty NOVANT_REPLAY_MODE or NOVANT_REPLAY_DIR.
+	ReplayMode string `json:"replayMode"`
+	ReplayDir  string `json:"replayDir"`
+	// LogLevel is the minimum level of request logs: debug, info (default),
+	// warn, or error.
+	LogLevel string `json:"logLevel"`
+}
+
+// ProjectSettings names an additional Novant project. Its API key is stored
+// in the "apiKey.<name>" secure field.
+type ProjectSettings struct {
+	Name string `json:"name"`
+}
+
+// loadSettings decodes and validates the jsonData of a data source instance.
+func loadSettings(settings backend.DataSourceInstanceSettings) (Settings, error) {
+	var s Settings
+	if len(settings.JSONData) > 0 {
+		if err := json.Unmarshal(settings.JSONData, &s); err != nil {
+			return s, fmt.Errorf("invalid data source settings: %w", err)
+		}
+	}
+
+	baseURL, err := normalizeBaseURL(s.BaseURL)
+	if err != nil {
+		return s, err
+	}
+	s.BaseURL = baseURL
+
+	if s.RetryMaxAttempts < 0 {
+		return s, fmt.Errorf("invalid retryMaxAttempts %d: must not be negative", s.RetryMaxAttempts)
+	}
+	if s.RetryBudgetSeconds < 0 {
+		return s, fmt.Errorf("invalid retryBudgetSeconds %d: must not be negative", s.RetryBudgetSeconds)
+	}
+	if s.RateBurst < 0 {
+		return s, fmt.Errorf("invalid rateBurst %d: must not be negative", s.RateBurst)
+	}
+	if s.PointBatchSize < 0 {
+		return s, fmt.Errorf("invalid pointBatchSize %d: must not be negative", s.PointBatchSize)
+	}
+	if _, err := parseLogLevel(s.LogLevel); err != nil {
+		return s, err
+	}
+	if err := applyReplayEnv(&s); err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// normalizeBaseURL validates a configured API base URL and strips any
+// trailing slash so API paths can be appended directly. An empty value
+// selects defaultBaseURL.
+func normalizeBaseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return defaultBaseURL, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid API base URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid API base URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid API base URL %q: missing host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid API base URL %q: must not contain a query or fragment", raw)
+	}
+
+	return strings.TrimRight(u.String(), "/"), nil
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// queryStats accumulates per-query measurements taken deep inside Client
+// (e.g. time spent waiting on the rate limiter) so the query handler can
+// surface them in frame metadata. It is carried on the request context.
+type queryStats struct {
+	limiterWait atomic.Int64 // nanoseconds
+	// roundTrip is the duration of the last HTTP attempt, from sending the
+	// request to reading the body, excluding limiter waits and retry
+	// backoff. In nanoseconds.
+	roundTrip atomic.Int64
+}
+
+type queryStatsKey struct{}
+
+// withQueryStats returns a child context carrying a fresh queryStats.
+func withQueryStats(ctx context.Context) (context.Context, *queryStats) {
+	s := &queryStats{}
+	return context.WithValue(ctx, queryStatsKey{}, s), s
+}
+
+// statsFromContext returns the queryStats on ctx, or nil if there is none.
+func statsFromContext(ctx context.Context) *queryStats {
+	s, _ := ctx.Value(queryStatsKey{}).(*queryStats)
+	return s
+}
+
+func (s *queryStats) addLimiterWait(d time.Duration) {
+	if s != nil && d > 0 {
+		s.limiterWait.Add(int64(d))
+	}
+}
+
+func (s *queryStats) setRoundTrip(d time.Duration) {
+	if s != nil {
+		s.roundTrip.Store(int64(d))
+	}
+}
+
+// lastRoundTrip returns the duration of the most recent HTTP attempt.
+func (s *queryStats) lastRoundTrip() time.Duration {
+	return time.Duration(s.roundTrip.Load())
+}
+
+// annotate adds the collected stats to every frame in resp as query
+// inspector stats. Nothing is added when no stat was recorded.
+func (s *queryStats) annotate(resp *backend.DataResponse) {
+	wait := time.Duration(s.limiterWait.Load())
+	if wait <= 0 {
+		return
+	}
+	stat := data.QueryStat{
+		FieldConfig: data.FieldConfig{DisplayName: "Rate limit wait", Unit: "ms"},
+		Value:       float64(wait) / float64(time.Millisecond),
+	}
+	for _, f := range resp.Frames {
+		if f.Meta == nil {
+			f.Meta = &data.FrameMeta{}
+		}
+		f.Meta.Stats = append(f.Meta.Stats, stat)
+	}
+}
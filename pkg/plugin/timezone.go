@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+)
+
+// naiveTrendLayout is the offset-less timestamp format some trend responses
+// use. Such timestamps are wall-clock times in the project's time zone.
+const naiveTrendLayout = "2006-01-02T15:04:05"
+
+// trendLocation returns the time zone for a trends response: its own tz
+// field if it names a loadable zone, otherwise fallback (the project's zone
+// from /v1/project).
+func trendLocation(tz string, fallback *time.Location) *time.Location {
+	if tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return fallback
+}
+
+// trendClock parses a sequence of trend timestamps in row order. RFC 3339
+// timestamps carry their own offset. Naive timestamps are read as wall-clock
+// time in loc; a wall-clock time that is repeated when DST ends is resolved
+// to its second occurrence when it would otherwise not move past the
+// previous row, so the hour after a fall-back transition is neither folded
+// onto the hour before nor reordered. Wall-clock times skipped when DST
+// starts do not occur in API data; Go normalizes any that do forward.
+type trendClock struct {
+	loc  *time.Location
+	prev time.Time
+}
+
+func newTrendClock(loc *time.Location) *trendClock {
+	return &trendClock{loc: loc}
+}
+
+func (c *trendClock) parse(ts string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err == nil {
+		c.prev = t
+		return t, nil
+	}
+	t, err = time.ParseInLocation(naiveTrendLayout, ts, c.loc)
+	if err != nil {
+		return t, fmt.Errorf("parsing timestamp %q: %w", ts, err)
+	}
+	if !c.prev.IsZero() && !t.After(c.prev) {
+		// Repeated wall-clock hour: the same reading an hour later is the
+		// other occurrence.
+		if later := t.Add(time.Hour); later.After(c.prev) && later.Format(naiveTrendLayout) == ts {
+			t = later
+		}
+	}
+	c.prev = t
+	return t, nil
+}
@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+const dstZone = "America/New_York"
+
+// utc parses an RFC 3339 UTC instant for expectations.
+func utc(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestTrendClockDST(t *testing.T) {
+	loc, err := time.LoadLocation(dstZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ts   []string
+		want []string // UTC instants
+	}{
+		{"spring naive hourly",
+			[]string{"2026-03-08T00:00:00", "2026-03-08T01:00:00", "2026-03-08T03:00:00", "2026-03-08T04:00:00"},
+			[]string{"2026-03-08T05:00:00Z", "2026-03-08T06:00:00Z", "2026-03-08T07:00:00Z", "2026-03-08T08:00:00Z"}},
+		{"spring RFC 3339 hourly",
+			[]string{"2026-03-08T01:00:00-05:00", "2026-03-08T03:00:00-04:00"},
+			[]string{"2026-03-08T06:00:00Z", "2026-03-08T07:00:00Z"}},
+		{"fall naive hourly repeats 01:00",
+			[]string{"2026-11-01T00:00:00", "2026-11-01T01:00:00", "2026-11-01T01:00:00", "2026-11-01T02:00:00"},
+			[]string{"2026-11-01T04:00:00Z", "2026-11-01T05:00:00Z", "2026-11-01T06:00:00Z", "2026-11-01T07:00:00Z"}},
+		{"fall naive 15min steps back",
+			[]string{"2026-11-01T01:30:00", "2026-11-01T01:45:00", "2026-11-01T01:00:00", "2026-11-01T01:15:00", "2026-11-01T02:00:00"},
+			[]string{"2026-11-01T05:30:00Z", "2026-11-01T05:45:00Z", "2026-11-01T06:00:00Z", "2026-11-01T06:15:00Z", "2026-11-01T07:00:00Z"}},
+		{"fall RFC 3339 hourly",
+			[]string{"2026-11-01T01:00:00-04:00", "2026-11-01T01:00:00-05:00", "2026-11-01T02:00:00-05:00"},
+			[]string{"2026-11-01T05:00:00Z", "2026-11-01T06:00:00Z", "2026-11-01T07:00:00Z"}},
+		{"fall mixed layouts",
+			[]string{"2026-11-01T01:00:00-04:00", "2026-11-01T01:00:00", "2026-11-01T02:00:00"},
+			[]string{"2026-11-01T05:00:00Z", "2026-11-01T06:00:00Z", "2026-11-01T07:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTrendClock(loc)
+			for i, ts := range tt.ts {
+				got, err := clock.parse(ts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := utc(t, tt.want[i]); !got.Equal(want) {
+					t.Errorf("%s = %s, want %s", ts, got.UTC().Format(time.RFC3339), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// A whole local day is requested with local-midnight boundaries, so the
+// spring day has 23 hourly rows and the fall day 25, however the API
+// renders the timestamps and whichever zone the dashboard range is in.
+func TestQueryTrendsDSTDays(t *testing.T) {
+	loc, _ := time.LoadLocation(dstZone)
+
+	tests := []struct {
+		day  time.Time
+		rows int
+	}{
+		{time.Date(2026, 3, 8, 0, 0, 0, 0, loc), 23},
+		{time.Date(2026, 11, 1, 0, 0, 0, 0, loc), 25},
+	}
+	for _, naive := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s naive=%v", tt.day.Format("2006-01-02"), naive), func(t *testing.T) {
+				b := novanttest.NewBuilding(novanttest.Options{Tz: dstZone})
+				ds, srv := newTestDatasource(t, b, nil)
+				srv.NaiveTimestamps = naive
+
+				from := tt.day.UTC()
+				to := tt.day.AddDate(0, 0, 1).Add(-time.Second).UTC()
+				resp := runQuery(t, ds, "trends", map[string]interface{}{"pointIds": "s.1.1", "interval": "1hr"}, from, to)
+				if resp.Error != nil {
+					t.Fatal(resp.Error)
+				}
+				times := resp.Frames[0].Fields[0]
+				if times.Len() != tt.rows {
+					t.Fatalf("%d rows, want %d", times.Len(), tt.rows)
+				}
+				for i := 0; i < times.Len(); i++ {
+					want := tt.day.Add(time.Duration(i) * time.Hour)
+					if got := times.At(i).(time.Time); !got.Equal(want) {
+						t.Errorf("row %d = %s, want %s", i, got.UTC().Format(time.RFC3339), want.UTC().Format(time.RFC3339))
+					}
+				}
+			})
+		}
+	}
+}
+
+// Two chunks meeting inside the fall-back day: the first renders naive
+// timestamps and the second RFC 3339, with the repeated 01:00 hour and the
+// boundary rows in both. The repeated hour stays two rows, the overlap is
+// de-duplicated, and the first non-null sample wins, whether the zone comes
+// from the responses or, without a tz, from the project.
+func TestMergeTrendsDSTChunkBoundary(t *testing.T) {
+	loc, _ := time.LoadLocation(dstZone)
+	for _, tz := range []string{dstZone, ""} {
+		t.Run("tz="+tz, func(t *testing.T) {
+			part := func(ts []string, vals ...interface{}) *TrendsResp {
+				s := &TrendSeries{}
+				for _, v := range vals {
+					s.append(v)
+				}
+				return &TrendsResp{Tz: tz, Interval: "1hr", PointIDs: []string{"s.1.1"}, Ts: ts, Series: map[string]*TrendSeries{"s.1.1": s}}
+			}
+			a := part([]string{"2026-10-31T23:00:00", "2026-11-01T00:00:00", "2026-11-01T01:00:00", "2026-11-01T01:00:00", "2026-11-01T02:00:00"},
+				1.0, 2.0, 3.0, 4.0, nil)
+			b := part([]string{"2026-11-01T01:00:00-05:00", "2026-11-01T02:00:00-05:00", "2026-11-01T03:00:00-05:00"},
+				40.0, 5.0, 6.0)
+
+			merged := mergeTrends([]*TrendsResp{a, b}, loc)
+			if err := merged.resolveTimes(trendLocation(merged.Tz, loc)); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []struct {
+				at  string
+				val interface{}
+			}{
+				{"2026-11-01T03:00:00Z", 1.0},
+				{"2026-11-01T04:00:00Z", 2.0},
+				{"2026-11-01T05:00:00Z", 3.0},
+				{"2026-11-01T06:00:00Z", 4.0},
+				{"2026-11-01T07:00:00Z", 5.0},
+				{"2026-11-01T08:00:00Z", 6.0},
+			}
+			if len(merged.Times) != len(want) {
+				t.Fatalf("merged %d rows (%v), want %d", len(merged.Times), merged.Ts, len(want))
+			}
+			s := merged.Series["s.1.1"]
+			for i, w := range want {
+				if got := merged.Times[i]; !got.Equal(utc(t, w.at)) {
+					t.Errorf("row %d at %s, want %s", i, got.UTC().Format(time.RFC3339), w.at)
+				}
+				if got := s.Value(i); got != w.val {
+					t.Errorf("row %d = %v, want %v", i, got, w.val)
+				}
+			}
+		})
+	}
+}
+
+// When /v1/project fails, trends are still served in UTC with a warning,
+// and the zone is looked up again on the next query.
+func TestQueryTrendsProjectTzUnavailable(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{Tz: dstZone})
+	ds, srv := newTestDatasource(t, b, map[string]interface{}{"retryMaxAttempts": 1})
+	srv.FailNext("/v1/project", http.StatusServiceUnavailable)
+
+	loc, _ := time.LoadLocation(dstZone)
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	model := map[string]interface{}{"pointIds": "s.1.1", "interval": "1hr"}
+
+	resp := runQuery(t, ds, "trends", model, day, day.Add(24*time.Hour-time.Second))
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	meta := resp.Frames[0].Meta
+	if len(meta.Notices) == 0 || !strings.Contains(meta.Notices[0].Text, "time zone unavailable") {
+		t.Errorf("notices = %v, want a time zone warning", meta.Notices)
+	}
+
+	resp = runQuery(t, ds, "trends", model, day, day.Add(24*time.Hour-time.Second))
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := len(resp.Frames[0].Meta.Notices); n != 0 {
+		t.Errorf("%d notices once /v1/project recovered, want 0", n)
+	}
+	if n := srv.Requests("/v1/project"); n != 2 {
+		t.Errorf("%d /v1/project requests, want 2 (the failure is not cached)", n)
+	}
+}
@@ -0,0 +1,431 @@
+package plugin
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// defaultTrendCacheMB is the trends cache budget when none is set.
+	defaultTrendCacheMB = 64
+	// closedDayTTL is how long a closed day's trends are kept. History
+	// does not change once a day is over, so this is bounded by the memory
+	// budget rather than by freshness.
+	closedDayTTL = 7 * 24 * time.Hour
+	// closeGrace is how long after local midnight a day is still treated
+	// as open, allowing for samples uploaded late by the site gateway.
+	closeGrace = time.Hour
+)
+
+func trendCacheBudget(settings Settings) int64 {
+	switch {
+	case settings.TrendCacheMB < 0:
+		return 0
+	case settings.TrendCacheMB == 0:
+		return defaultTrendCacheMB << 20
+	}
+	return int64(settings.TrendCacheMB) << 20
+}
+
+// trendBucketKey identifies one point's trend samples for one project-local
+// day at one interval and aggregate. tz names the zone the day was split in,
+// so a bucket split on another zone's midnights is never read back as this
+// one's day.
+type trendBucketKey struct {
+	project, point, interval, aggregate, tz, day string
+}
+
+// trendBucket holds one point's samples for one day. Buckets filled by the
+// same fetch share their ts slice, and segment identifies it so the day can
+// be reassembled as one part rather than one per point.
+type trendBucket struct {
+	key     trendBucketKey
+	ts      []string
+	segment uint64
+	series  *TrendSeries
+	size    int64
+	expires time.Time
+	elem    *list.Element
+}
+
+// trendCache caches trends in per-point, per-day buckets so overlapping
+// dashboard ranges and refreshes only fetch what is missing: closed days
+// are kept for closedDayTTL and the current day is refetched on the
+// valueCacheTTL cadence. Memory is bounded by budget bytes (estimated),
+// evicting least recently used buckets first. One cache is shared by every
+// project of a data source.
+type trendCache struct {
+	budget int64
+
+	mu      sync.Mutex
+	used    int64
+	buckets map[trendBucketKey]*trendBucket
+	lru     *list.List // front is most recently used
+	segment uint64
+}
+
+func newTrendCache(budget int64) *trendCache {
+	return &trendCache{
+		budget:  budget,
+		buckets: make(map[trendBucketKey]*trendBucket),
+		lru:     list.New(),
+	}
+}
+
+func (c *trendCache) enabled() bool {
+	return c != nil && c.budget > 0
+}
+
+// get returns the bucket for key if it is cached and fresh.
+func (c *trendCache) get(key trendBucketKey, now time.Time) (*trendBucket, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.buckets[key]
+	if ok && now.After(b.expires) {
+		c.remove(b)
+		cacheEvictions.WithLabelValues("trends").Inc()
+		ok = false
+	}
+	recordCacheLookup("trends", ok)
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(b.elem)
+	return b, true
+}
+
+// put stores b, evicting least recently used buckets to stay in budget.
+func (c *trendCache) put(b *trendBucket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.buckets[b.key]; ok {
+		c.remove(old)
+	}
+	b.elem = c.lru.PushFront(b)
+	c.buckets[b.key] = b
+	c.used += b.size
+	for c.used > c.budget && c.lru.Len() > 0 {
+		c.remove(c.lru.Back().Value.(*trendBucket))
+		cacheEvictions.WithLabelValues("trends").Inc()
+	}
+}
+
+// nextSegment returns a new ID for buckets that share a ts slice.
+func (c *trendCache) nextSegment() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.segment++
+	return c.segment
+}
+
+func (c *trendCache) remove(b *trendBucket) {
+	c.lru.Remove(b.elem)
+	delete(c.buckets, b.key)
+	c.used -= b.size
+}
+
+// clearProject drops every bucket of the named project.
+func (c *trendCache) clearProject(project string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, b := range c.buckets {
+		if key.project == project {
+			c.remove(b)
+			cacheEvictions.WithLabelValues("trends").Inc()
+		}
+	}
+}
+
+// bucketSize estimates the memory held by a bucket. The shared ts slice is
+// charged to every bucket that uses it, which overestimates.
+func bucketSize(ts []string, s *TrendSeries) int64 {
+	size := int64(200)
+	for _, t := range ts {
+		size += int64(len(t)) + 16
+	}
+	size += int64(len(s.Kinds)) * 9
+	size += int64(len(s.Other)) * 48
+	return size
+}
+
+// dayExpiry returns when a bucket for the given local day should expire:
+// closed days live for closedDayTTL, the current (or a future) day only for
+// valueCacheTTL.
+func dayExpiry(day, now time.Time) time.Time {
+	if now.After(day.AddDate(0, 0, 1).Add(closeGrace)) {
+		return now.Add(closedDayTTL)
+	}
+	return now.Add(valueCacheTTL)
+}
+
+// dayBucketed reports whether trends at interval can be cached in per-day
+// buckets. Every row of a finer interval falls within one day, so a day's
+// rows are the same whichever range they were fetched with. A month row
+// aggregates the requested days of its month, so it depends on the range
+// and is never cached.
+func dayBucketed(interval string) bool {
+	return interval != "1mo"
+}
+
+// trendRun is a run of consecutive days that are missing the same points.
+type trendRun struct {
+	start, end time.Time
+	points     []string
+}
+
+// cachedTrends returns trends for the local days startDay to endDay from
+// the cache, fetching only the (point, day) buckets that are missing or
+// stale. Consecutive days missing the same points are fetched together, so
+// a refresh normally costs one request for the current day, and the runs
+// are fetched concurrently. Runs that fail are reported as warning notices
+// as long as something could be returned. loc must be the project's own
+// time zone, not a fallback: days are split and cached on its midnights.
+func (p *project) cachedTrends(ctx context.Context, pointIDs string, startDay, endDay time.Time, interval, aggregate string, loc *time.Location) (*TrendsResp, []data.Notice, error) {
+	ctx, span := startSpan(ctx, "novant.cache.trends", attribute.String("novant.interval", interval))
+	defer span.End()
+
+	var ids []string
+	if all := splitPointIDs(pointIDs, math.MaxInt); len(all) == 1 {
+		ids = strings.Split(all[0], ",")
+	}
+	var days []time.Time
+	for d := startDay; !d.After(endDay); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	now := time.Now()
+	key := func(pt string, day time.Time) trendBucketKey {
+		return trendBucketKey{p.name, pt, interval, aggregate, loc.String(), day.Format("2006-01-02")}
+	}
+
+	// Look up every bucket and group the misses into runs.
+	var found []*trendBucket
+	var runs []trendRun
+	hits := 0
+	for _, day := range days {
+		var missing []string
+		for _, pt := range ids {
+			if b, ok := p.trendCache.get(key(pt, day), now); ok {
+				found = append(found, b)
+				hits++
+				continue
+			}
+			missing = append(missing, pt)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1].end.AddDate(0, 0, 1).Equal(day) && sameIDs(runs[n-1].points, missing) {
+			runs[n-1].end = day
+			continue
+		}
+		runs = append(runs, trendRun{start: day, end: day, points: missing})
+	}
+	span.SetAttributes(attribute.Int("novant.buckets.hit", hits), attribute.Int("novant.fetches", len(runs)))
+	loggerFromContext(ctx).Debug("Trends cache lookup", "buckets", len(days)*len(ids), "hits", hits, "fetches", len(runs))
+
+	// Runs are fetched concurrently, as the chunks of a run are (see
+	// getTrendsChunked); the client's limiter bounds the concurrency.
+	type runResult struct {
+		resp    *TrendsResp
+		notices []data.Notice
+		skip    []trendChunk
+		err     error
+	}
+	results := make([]runResult, len(runs))
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		wg.Add(1)
+		go func(r *runResult, run trendRun) {
+			defer wg.Done()
+			r.resp, r.notices, r.skip, r.err = p.fetchTrends(ctx, strings.Join(run.points, ","), run.start, run.end, interval, aggregate)
+			if r.err == nil {
+				r.err = r.resp.resolveTimes(trendLocation(r.resp.Tz, loc))
+			}
+		}(&results[i], run)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var notices []data.Notice
+	var failed []string
+	var firstErr error
+	for i, run := range runs {
+		r := results[i]
+		notices = append(notices, r.notices...)
+		if r.err != nil {
+			failed = append(failed, fmt.Sprintf("%s to %s: %v",
+				run.start.Format("2006-01-02"), run.end.Format("2006-01-02"), r.err))
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		found = append(found, p.storeTrends(r.resp, run, r.skip, key, loc, now)...)
+	}
+	if firstErr != nil && len(failed) == len(runs) && hits == 0 {
+		return nil, nil, firstErr
+	}
+	if len(failed) > 0 {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "Partial data: some days could not be fetched (" + strings.Join(failed, "; ") + ")",
+		})
+	}
+	return assembleTrends(found, ids, interval, aggregate, loc), notices, nil
+}
+
+// storeTrends splits a fetched response into per-point, per-day buckets,
+// caches them, and returns them. Days of the run without any rows are
+// cached as empty buckets so they are not fetched again, except for days in
+// skip, the chunks that failed to fetch: those are left out, so the gap
+// is reported now and fetched again on the next query.
+func (p *project) storeTrends(resp *TrendsResp, run trendRun, skip []trendChunk, key func(string, time.Time) trendBucketKey, loc *time.Location, now time.Time) []*trendBucket {
+	var out []*trendBucket
+	row := 0
+	for day := run.start; !day.After(run.end); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		for row < len(resp.Times) && resp.Times[row].Before(day) {
+			row++
+		}
+		lo := row
+		for row < len(resp.Times) && resp.Times[row].Before(next) {
+			row++
+		}
+		if inChunks(day, skip) {
+			continue
+		}
+		ts := append([]string(nil), resp.Ts[lo:row]...)
+		segment := p.trendCache.nextSegment()
+		expires := dayExpiry(day, now)
+
+		for _, pt := range run.points {
+			s := &TrendSeries{}
+			if src, ok := resp.Series[pt]; ok {
+				s = sliceSeries(src, lo, row)
+			} else {
+				s.padTo(row - lo)
+			}
+			b := &trendBucket{
+				key:     key(pt, day),
+				ts:      ts,
+				segment: segment,
+				series:  s,
+				size:    bucketSize(ts, s),
+				expires: expires,
+			}
+			p.trendCache.put(b)
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// inChunks reports whether day falls within any of chunks.
+func inChunks(day time.Time, chunks []trendChunk) bool {
+	for _, c := range chunks {
+		if !day.Before(c.start) && !day.After(c.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// sliceSeries copies rows [lo, hi) of s into a new series.
+func sliceSeries(s *TrendSeries, lo, hi int) *TrendSeries {
+	out := &TrendSeries{
+		Kinds: append([]SampleKind(nil), s.Kinds[lo:hi]...),
+		Nums:  append([]float64(nil), s.Nums[lo:hi]...),
+	}
+	for row, v := range s.Other {
+		if row >= lo && row < hi {
+			if out.Other == nil {
+				out.Other = make(map[int]interface{})
+			}
+			out.Other[row-lo] = v
+		}
+	}
+	return out
+}
+
+// assembleTrends joins buckets back into one response, ordered as ids.
+// Buckets filled together for a day form one part; the parts are stitched
+// by mergeTrends. Series are shallow-copied so later trimming never
+// touches the cached buckets.
+func assembleTrends(buckets []*trendBucket, ids []string, interval, aggregate string, loc *time.Location) *TrendsResp {
+	type partKey struct {
+		day     string
+		segment uint64
+	}
+	parts := make(map[partKey]*TrendsResp)
+	var order []partKey
+	for _, b := range buckets {
+		if len(b.ts) == 0 {
+			continue
+		}
+		k := partKey{b.key.day, b.segment}
+		part, ok := parts[k]
+		if !ok {
+			part = &TrendsResp{
+				Tz:        loc.String(),
+				Interval:  interval,
+				Aggregate: aggregate,
+				Ts:        b.ts,
+				Series:    make(map[string]*TrendSeries),
+			}
+			parts[k] = part
+			order = append(order, k)
+		}
+		s := *b.series
+		part.PointIDs = append(part.PointIDs, b.key.point)
+		part.Series[b.key.point] = &s
+	}
+
+	list := make([]*TrendsResp, 0, len(order))
+	for _, k := range order {
+		list = append(list, parts[k])
+	}
+	if len(list) == 0 {
+		resp := &TrendsResp{Tz: loc.String(), Interval: interval, Aggregate: aggregate, PointIDs: ids, Series: make(map[string]*TrendSeries)}
+		for _, id := range ids {
+			resp.Series[id] = &TrendSeries{}
+		}
+		return resp
+	}
+
+	merged := mergeTrends(list, loc)
+	merged.PointIDs = ids
+	for _, id := range ids {
+		if _, ok := merged.Series[id]; !ok {
+			s := &TrendSeries{}
+			s.padTo(len(merged.Ts))
+			merged.Series[id] = s
+		}
+	}
+	return merged
+}
+
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
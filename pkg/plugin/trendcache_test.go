@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+// pointList returns the IDs of the first n points of source s.1.
+func pointList(n int) string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("s.1.%d", i+1)
+	}
+	return strings.Join(ids, ",")
+}
+
+// A chunk that fails leaves a gap with a notice, and its days are not
+// cached: the next query fetches them again and fills the gap.
+func TestCachedTrendsSkipFailedChunks(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{PointsPerSource: 50})
+	ds, srv := newTestDatasource(t, b, map[string]interface{}{"retryMaxAttempts": 1})
+	loc, _ := time.LoadLocation(b.Tz)
+
+	// 5min × 50 points gives 17-day chunks, so 40 days is three chunks.
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 40).Add(-time.Second)
+	model := map[string]interface{}{"pointIds": pointList(50), "interval": "5min"}
+	const wantRows = 40 * 288
+
+	srv.FailNext("/v1/trends", http.StatusInternalServerError)
+	resp := runQuery(t, ds, "trends", model, from, to)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if rows, _ := resp.Frames[0].RowLen(); rows >= wantRows {
+		t.Fatalf("%d rows despite a failed chunk", rows)
+	}
+	if n := len(resp.Frames[0].Meta.Notices); n == 0 {
+		t.Fatal("no partial data notice")
+	}
+
+	before := srv.Requests("/v1/trends")
+	resp = runQuery(t, ds, "trends", model, from, to)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if srv.Requests("/v1/trends") == before {
+		t.Error("failed days were served from the cache")
+	}
+	if rows, _ := resp.Frames[0].RowLen(); rows != wantRows {
+		t.Errorf("%d rows, want %d", rows, wantRows)
+	}
+	if resp.Frames[0].Meta != nil && len(resp.Frames[0].Meta.Notices) > 0 {
+		t.Errorf("unexpected notices: %v", resp.Frames[0].Meta.Notices)
+	}
+
+	// Every day is cached now.
+	before = srv.Requests("/v1/trends")
+	if resp := runQuery(t, ds, "trends", model, from, to); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := srv.Requests("/v1/trends") - before; n != 0 {
+		t.Errorf("%d requests for a fully cached range", n)
+	}
+}
+
+// Month rows depend on the days requested, so they bypass the day cache.
+func TestMonthlyTrendsNotCached(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, srv := newTestDatasource(t, b, nil)
+	loc, _ := time.LoadLocation(b.Tz)
+
+	from := time.Date(2026, 1, 15, 0, 0, 0, 0, loc)
+	to := time.Date(2026, 3, 20, 0, 0, 0, 0, loc)
+	model := map[string]interface{}{"pointIds": "s.1.1", "interval": "1mo"}
+	for i := 1; i <= 2; i++ {
+		if resp := runQuery(t, ds, "trends", model, from, to); resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+		if n := srv.Requests("/v1/trends"); n != i {
+			t.Errorf("after query %d: %d requests, want %d", i, n, i)
+		}
+	}
+	if !dayBucketed("1day") || dayBucketed("1mo") {
+		t.Error("dayBucketed: want 1day cached and 1mo not")
+	}
+}
+
+// A query served in UTC because /v1/project failed caches nothing, so once
+// the zone is known the day is fetched again and split on local midnights.
+func TestTrendsFallbackZoneNotCached(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{Tz: "America/New_York"})
+	ds, srv := newTestDatasource(t, b, map[string]interface{}{"retryMaxAttempts": 1})
+	loc, _ := time.LoadLocation(b.Tz)
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 1).Add(-time.Second)
+	model := map[string]interface{}{"pointIds": "s.1.1", "interval": "1hr"}
+
+	srv.FailNext("/v1/project", http.StatusServiceUnavailable)
+	for i, wantRequests := range []int{1, 2, 2} {
+		resp := runQuery(t, ds, "trends", model, from, to)
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+		if rows, _ := resp.Frames[0].RowLen(); rows != 24 {
+			t.Errorf("query %d: %d rows, want 24", i+1, rows)
+		}
+		if n := srv.Requests("/v1/trends"); n != wantRequests {
+			t.Errorf("query %d: %d trends requests, want %d", i+1, n, wantRequests)
+		}
+	}
+}
+
+// Buckets are keyed by the zone their days were split in.
+func TestTrendCacheKeyedByZone(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{Tz: "America/New_York"})
+	ds, srv := newTestDatasource(t, b, nil)
+	p := ds.projects[0]
+	ny, _ := time.LoadLocation(b.Tz)
+	ctx := context.Background()
+
+	for i, loc := range []*time.Location{ny, time.UTC, ny} {
+		day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+		if _, _, err := p.cachedTrends(ctx, "s.1.1", day, day, "1hr", "", loc); err != nil {
+			t.Fatal(err)
+		}
+		if want := min(i+1, 2); srv.Requests("/v1/trends") != want {
+			t.Errorf("lookup %d in %s: %d requests, want %d", i+1, loc, srv.Requests("/v1/trends"), want)
+		}
+	}
+}
+
+// Days missing different points are fetched as separate runs, all at once,
+// and assembled back onto one timeline.
+func TestCachedTrendsRuns(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, srv := newTestDatasource(t, b, nil)
+	loc, _ := time.LoadLocation(b.Tz)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, loc) }
+	query := func(ids string, from, to time.Time) backend.DataResponse {
+		return runQuery(t, ds, "trends", map[string]interface{}{"pointIds": ids, "interval": "1hr"}, from, to.AddDate(0, 0, 1).Add(-time.Second))
+	}
+
+	// Cache s.1.1 on the 2nd and 4th only.
+	for _, d := range []int{2, 4} {
+		if resp := query("s.1.1", day(d), day(d)); resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+	}
+	before := srv.Requests("/v1/trends")
+
+	// The 1st, 3rd and 5th miss both points and the 2nd and 4th only s.1.2:
+	// five single-day runs.
+	resp := query("s.1.1,s.1.2", day(1), day(5))
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := srv.Requests("/v1/trends") - before; n != 5 {
+		t.Errorf("%d requests, want 5", n)
+	}
+	// The same frame as a query against a cold cache.
+	fresh, _ := newTestDatasource(t, b, nil)
+	want := runQuery(t, fresh, "trends", map[string]interface{}{"pointIds": "s.1.1,s.1.2", "interval": "1hr"}, day(1), day(6).Add(-time.Second))
+	f, wf := resp.Frames[0], want.Frames[0]
+	if rows, _ := f.RowLen(); rows != 5*24 {
+		t.Fatalf("%d rows, want %d", rows, 5*24)
+	}
+	for i, field := range f.Fields {
+		for row := 0; row < field.Len(); row++ {
+			got, _ := field.ConcreteAt(row)
+			exp, _ := wf.Fields[i].ConcreteAt(row)
+			if got != exp {
+				t.Fatalf("%s row %d = %v, want %v", field.Name, row, got, exp)
+			}
+		}
+	}
+}
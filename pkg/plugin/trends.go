@@ -0,0 +1,461 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SampleKind is the JSON type of a single trend sample.
+type SampleKind uint8
+
+const (
+	SampleNull SampleKind = iota
+	SampleNumber
+	SampleBool
+	SampleString
+)
+
+// TrendSeries is one point's column of trend samples. Kinds has one entry
+// per row; Nums holds the value for SampleNumber rows (zero otherwise) and
+// Other holds bool and string samples keyed by row, which are rare enough
+// that a sparse map beats a dense column.
+type TrendSeries struct {
+	Kinds []SampleKind
+	Nums  []float64
+	Other map[int]interface{}
+}
+
+// Len returns the number of rows in the series.
+func (s *TrendSeries) Len() int {
+	return len(s.Kinds)
+}
+
+// Float returns the numeric sample at row i, or false if the sample is null
+// or non-numeric.
+func (s *TrendSeries) Float(i int) (float64, bool) {
+	if s.Kinds[i] != SampleNumber {
+		return 0, false
+	}
+	return s.Nums[i], true
+}
+
+// Value returns the sample at row i as float64, bool, string, or nil.
+func (s *TrendSeries) Value(i int) interface{} {
+	switch s.Kinds[i] {
+	case SampleNumber:
+		return s.Nums[i]
+	case SampleBool, SampleString:
+		return s.Other[i]
+	}
+	return nil
+}
+
+// padTo appends null samples until the series has n rows.
+func (s *TrendSeries) padTo(n int) {
+	for len(s.Kinds) < n {
+		s.Kinds = append(s.Kinds, SampleNull)
+		s.Nums = append(s.Nums, 0)
+	}
+}
+
+// append adds v (float64, bool, string, or nil) as the next row. Any other
+// type is stored as null.
+func (s *TrendSeries) append(v interface{}) {
+	row := len(s.Kinds)
+	switch val := v.(type) {
+	case float64:
+		s.Kinds = append(s.Kinds, SampleNumber)
+		s.Nums = append(s.Nums, val)
+		return
+	case bool:
+		s.Kinds = append(s.Kinds, SampleBool)
+	case string:
+		s.Kinds = append(s.Kinds, SampleString)
+	default:
+		s.Kinds = append(s.Kinds, SampleNull)
+		s.Nums = append(s.Nums, 0)
+		return
+	}
+	s.Nums = append(s.Nums, 0)
+	if s.Other == nil {
+		s.Other = make(map[int]interface{})
+	}
+	s.Other[row] = v
+}
+
+// resolveTimes parses Ts into Times, reading naive timestamps in loc.
+func (resp *TrendsResp) resolveTimes(loc *time.Location) error {
+	clock := newTrendClock(loc)
+	resp.Times = make([]time.Time, len(resp.Ts))
+	for i, ts := range resp.Ts {
+		t, err := clock.parse(ts)
+		if err != nil {
+			return err
+		}
+		resp.Times[i] = t
+	}
+	return nil
+}
+
+// trim drops the rows of resp outside [from, to]. /v1/trends only takes
+// whole days, so without this a short dashboard range would render the rest
+// of the day around it. A row's timestamp is the start of its interval
+// bucket, so the row whose bucket contains from is kept: an hourly row at
+// 13:00 covers a 13:10 to 13:40 range. Times must be resolved. Rows are in
+// time order, so the kept rows are one contiguous run and the series are
+// resliced rather than copied. It returns the number of rows dropped.
+func (resp *TrendsResp) trim(from, to time.Time, interval string) int {
+	lo, hi := 0, len(resp.Times)
+	for lo < hi && !bucketEnd(resp.Times[lo], interval).After(from) {
+		lo++
+	}
+	for hi > lo && resp.Times[hi-1].After(to) {
+		hi--
+	}
+	dropped := len(resp.Ts) - (hi - lo)
+	if dropped == 0 {
+		return 0
+	}
+
+	resp.Ts = resp.Ts[lo:hi]
+	resp.Times = resp.Times[lo:hi]
+	for _, s := range resp.Series {
+		s.Kinds = s.Kinds[lo:hi]
+		s.Nums = s.Nums[lo:hi]
+		if s.Other != nil {
+			other := make(map[int]interface{})
+			for row, v := range s.Other {
+				if row >= lo && row < hi {
+					other[row-lo] = v
+				}
+			}
+			s.Other = other
+		}
+	}
+	return dropped
+}
+
+// bucketEnd returns the end of the interval bucket starting at t. Day and
+// month buckets follow the calendar in t's location, so they stay on local
+// midnight across DST changes. Raw samples are instants and end where they
+// start.
+func bucketEnd(t time.Time, interval string) time.Time {
+	switch interval {
+	case "1day":
+		return t.AddDate(0, 0, 1)
+	case "1mo":
+		return t.AddDate(0, 1, 0)
+	}
+	if step, ok := intervalStep(interval); ok {
+		return t.Add(step)
+	}
+	return t.Add(time.Nanosecond)
+}
+
+// trendsDecoder decodes a /v1/trends body straight from the response stream
+// into TrendsResp columns. The envelope is walked token by token and each row
+// is scanned in place from a reused buffer, so neither the raw body nor a
+// per-row map is ever held in memory, and numeric samples decode without
+// allocating.
+type trendsDecoder struct {
+	resp *TrendsResp
+}
+
+func (t trendsDecoder) decodeJSON(dec *json.Decoder) error {
+	return decodeTrends(dec, t.resp)
+}
+
+// decodeTrends reads a trends response object from dec into resp. Rows are
+// appended as they stream in, so resp is reset first: a retried request
+// decodes into the same value and must not build on a partial attempt.
+func decodeTrends(dec *json.Decoder, resp *TrendsResp) error {
+	*resp = TrendsResp{Series: make(map[string]*TrendSeries)}
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "start":
+			err = dec.Decode(&resp.Start)
+		case "end":
+			err = dec.Decode(&resp.End)
+		case "tz":
+			err = dec.Decode(&resp.Tz)
+		case "interval":
+			err = dec.Decode(&resp.Interval)
+		case "aggregate":
+			err = dec.Decode(&resp.Aggregate)
+		case "point_ids":
+			err = dec.Decode(&resp.PointIDs)
+		case "trends":
+			err = decodeTrendRows(dec, resp)
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return fmt.Errorf("decoding trends %q: %w", key, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	// Points listed in point_ids but never present in a row still get an
+	// all-null series, and short series are padded to the full row count.
+	// This waits for the whole object, since point_ids may follow trends.
+	for _, pid := range resp.PointIDs {
+		if _, ok := resp.Series[pid]; !ok {
+			resp.Series[pid] = &TrendSeries{}
+		}
+	}
+	for _, s := range resp.Series {
+		s.padTo(len(resp.Ts))
+	}
+	return nil
+}
+
+// decodeTrendRows reads the "trends" array. Each row is an object with a
+// "ts" key and one key per point ID; points missing from a row are padded
+// with nulls as later rows arrive, so every series stays aligned with
+// resp.Ts.
+func decodeTrendRows(dec *json.Decoder, resp *TrendsResp) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	for dec.More() {
+		// The decoder validates the row; RawMessage reuses its buffer.
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		row := len(resp.Ts)
+		resp.Ts = append(resp.Ts, "")
+		if err := decodeTrendRow(raw, row, resp); err != nil {
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// decodeTrendRow scans one row object b, already validated by the decoder,
+// into resp at row. Keys without escapes are looked up straight from b, so
+// the only allocations are the timestamp and new series.
+func decodeTrendRow(b []byte, row int, resp *TrendsResp) error {
+	i := skipSpace(b, 0)
+	if i == len(b) || b[i] != '{' {
+		return fmt.Errorf("expected {, got %.20s", b[i:])
+	}
+	for i = skipSpace(b, i+1); b[i] != '}'; i = skipSpace(b, i) {
+		if b[i] == ',' {
+			i = skipSpace(b, i+1)
+		}
+		end := stringEnd(b, i)
+		rawKey := b[i:end]
+		i = skipSpace(b, end)
+		i = skipSpace(b, i+1) // ':'
+		end = valueEnd(b, i)
+		val := b[i:end]
+		i = end
+
+		if key, ok := plainString(rawKey); ok && string(key) == "ts" {
+			if err := unquote(val, &resp.Ts[row]); err != nil {
+				return fmt.Errorf("ts: %w", err)
+			}
+			continue
+		}
+		s, err := resp.series(rawKey)
+		if err != nil {
+			return err
+		}
+		s.padTo(row)
+		if s.Len() != row {
+			continue // duplicate key; the first sample wins
+		}
+		if err := s.appendJSON(val); err != nil {
+			return fmt.Errorf("%s: %w", rawKey, err)
+		}
+	}
+	return nil
+}
+
+// series returns the series for the quoted JSON key rawKey, creating it on
+// first use.
+func (resp *TrendsResp) series(rawKey []byte) (*TrendSeries, error) {
+	if key, ok := plainString(rawKey); ok {
+		if s, ok := resp.Series[string(key)]; ok {
+			return s, nil
+		}
+	}
+	var key string
+	if err := unquote(rawKey, &key); err != nil {
+		return nil, err
+	}
+	s, ok := resp.Series[key]
+	if !ok {
+		s = &TrendSeries{}
+		resp.Series[key] = s
+	}
+	return s, nil
+}
+
+// appendJSON adds the JSON scalar b as the next row. Nested objects and
+// arrays are read as null.
+func (s *TrendSeries) appendJSON(b []byte) error {
+	switch b[0] {
+	case 'n', '{', '[':
+		s.append(nil)
+	case 't':
+		s.append(true)
+	case 'f':
+		s.append(false)
+	case '"':
+		var v string
+		if err := unquote(b, &v); err != nil {
+			return err
+		}
+		s.append(v)
+	default:
+		f, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return err
+		}
+		s.Kinds = append(s.Kinds, SampleNumber)
+		s.Nums = append(s.Nums, f)
+	}
+	return nil
+}
+
+// plainString returns the contents of the quoted JSON string b if it has no
+// escapes, so it can be used without unquoting.
+func plainString(b []byte) ([]byte, bool) {
+	inner := b[1 : len(b)-1]
+	return inner, bytes.IndexByte(inner, '\\') < 0
+}
+
+// unquote decodes the JSON string b into v. Anything other than a string
+// without escapes goes through json.Unmarshal, so null leaves v unchanged.
+func unquote(b []byte, v *string) error {
+	if b[0] == '"' {
+		if inner, ok := plainString(b); ok {
+			*v = string(inner)
+			return nil
+		}
+	}
+	return json.Unmarshal(b, v)
+}
+
+// skipSpace returns the index of the first non-space byte in b at or after i.
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t' || b[i] == '\n' || b[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// stringEnd returns the index just past the JSON string starting at b[i].
+func stringEnd(b []byte, i int) int {
+	for j := i + 1; j < len(b); j++ {
+		switch b[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(b)
+}
+
+// valueEnd returns the index just past the JSON value starting at b[i].
+func valueEnd(b []byte, i int) int {
+	switch b[i] {
+	case '"':
+		return stringEnd(b, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(b); j++ {
+			switch b[j] {
+			case '"':
+				j = stringEnd(b, j) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(b)
+	}
+	j := i
+	for j < len(b) && b[j] != ',' && b[j] != '}' && b[j] != ']' &&
+		b[j] != ' ' && b[j] != '\t' && b[j] != '\n' && b[j] != '\r' {
+		j++
+	}
+	return j
+}
+
+// readKey reads an object key.
+func readKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("expected object key, got %v", tok)
+	}
+	return key, nil
+}
+
+// skipValue discards the next value, whatever its type.
+func skipValue(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); ok {
+		return skipNested(dec, d)
+	}
+	return nil
+}
+
+// skipNested discards tokens until the container opened by d is closed.
+func skipNested(dec *json.Decoder, d json.Delim) error {
+	if d != '{' && d != '[' {
+		return fmt.Errorf("unexpected %v", d)
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			default:
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// expectDelim reads the next token and checks it is want.
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %v, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/novant-io/novant-grafana/pkg/novanttest"
+)
+
+func TestDecodeTrends(t *testing.T) {
+	body := `{"tz":"UTC","interval":"1hr","point_ids":["s.1.1","s.1.2","s.1.3"],"extra":{"a":[1,2]},"trends":[
+		{"ts":"2026-01-01T00:00:00","s.1.1":71.5,"s.1.2":true},
+		{"ts":"2026-01-01T01:00:00","s.1.1":null,"s.1.2":"on","s.9.9":1},
+		{"ts":"2026-01-01T02:00:00","s.1.1":72}
+	]}`
+
+	var resp TrendsResp
+	if err := decodeTrends(json.NewDecoder(strings.NewReader(body)), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(resp.Ts, ","); got != "2026-01-01T00:00:00,2026-01-01T01:00:00,2026-01-01T02:00:00" {
+		t.Fatalf("ts = %s", got)
+	}
+	tests := []struct {
+		pid  string
+		want []interface{}
+	}{
+		{"s.1.1", []interface{}{71.5, nil, 72.0}},
+		{"s.1.2", []interface{}{true, "on", nil}},
+		{"s.1.3", []interface{}{nil, nil, nil}},
+		{"s.9.9", []interface{}{nil, 1.0, nil}},
+	}
+	for _, tt := range tests {
+		s, ok := resp.Series[tt.pid]
+		if !ok {
+			t.Errorf("%s: missing series", tt.pid)
+			continue
+		}
+		if s.Len() != len(tt.want) {
+			t.Errorf("%s: len = %d, want %d", tt.pid, s.Len(), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			if got := s.Value(i); got != w {
+				t.Errorf("%s[%d] = %v, want %v", tt.pid, i, got, w)
+			}
+		}
+	}
+}
+
+func TestDecodeTrendsLayouts(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ts   []string
+		want map[string][]interface{}
+	}{
+		{"point_ids after trends",
+			`{"trends":[{"ts":"a","s.1.1":1},{"ts":"b"}],"point_ids":["s.1.1","s.1.2"]}`,
+			[]string{"a", "b"},
+			map[string][]interface{}{"s.1.1": {1.0, nil}, "s.1.2": {nil, nil}}},
+		{"no trends key",
+			`{"point_ids":["s.1.1"]}`,
+			nil,
+			map[string][]interface{}{"s.1.1": {}}},
+		{"whitespace and key order",
+			"{ \"trends\" : [ { \"s.1.1\" : -1.5e2 ,\n\t\"ts\" : \"a\" } ] }",
+			[]string{"a"},
+			map[string][]interface{}{"s.1.1": {-150.0}}},
+		{"escaped keys and strings",
+			`{"trends":[{"ts":"a\u0062","s.1.1":"x\"y","s.1.2":"{[}","s\u002e1.3":2}]}`,
+			[]string{"ab"},
+			map[string][]interface{}{"s.1.1": {`x"y`}, "s.1.2": {"{[}"}, "s.1.3": {2.0}}},
+		{"nested values read as null",
+			`{"trends":[{"ts":"a","s.1.1":{"v":[1,"]"]},"s.1.2":[{}],"s.1.3":false}]}`,
+			[]string{"a"},
+			map[string][]interface{}{"s.1.1": {nil}, "s.1.2": {nil}, "s.1.3": {false}}},
+		{"duplicate key keeps first",
+			`{"trends":[{"ts":"a","s.1.1":1,"s.1.1":2}]}`,
+			[]string{"a"},
+			map[string][]interface{}{"s.1.1": {1.0}}},
+		{"null ts",
+			`{"trends":[{"ts":null,"s.1.1":1}]}`,
+			[]string{""},
+			map[string][]interface{}{"s.1.1": {1.0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp TrendsResp
+			if err := decodeTrends(json.NewDecoder(strings.NewReader(tt.body)), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(resp.Ts, ",") != strings.Join(tt.ts, ",") {
+				t.Errorf("ts = %q, want %q", resp.Ts, tt.ts)
+			}
+			if len(resp.Series) != len(tt.want) {
+				t.Errorf("%d series, want %d", len(resp.Series), len(tt.want))
+			}
+			for pid, want := range tt.want {
+				s, ok := resp.Series[pid]
+				if !ok {
+					t.Errorf("%s: missing series", pid)
+					continue
+				}
+				if s.Len() != len(want) {
+					t.Errorf("%s: len = %d, want %d", pid, s.Len(), len(want))
+					continue
+				}
+				for i, w := range want {
+					if got := s.Value(i); got != w {
+						t.Errorf("%s[%d] = %v, want %v", pid, i, got, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeTrendsInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"trends":[1]}`,
+		`{"trends":[{"ts":"a","s.1.1":1}`,
+		`{"trends":[{"ts":1}]}`,
+	} {
+		var resp TrendsResp
+		if err := decodeTrends(json.NewDecoder(strings.NewReader(body)), &resp); err == nil {
+			t.Errorf("%s: decoded without error", body)
+		}
+	}
+}
+
+// A retried request decodes into the same TrendsResp; rows from a failed
+// attempt must not survive into the next one.
+func TestDecodeTrendsResetsOnRetry(t *testing.T) {
+	full := `{"point_ids":["s.1.1"],"trends":[{"ts":"2026-01-01T00:00:00","s.1.1":1},{"ts":"2026-01-01T01:00:00","s.1.1":2}]}`
+	partial := full[:strings.Index(full, `{"ts":"2026-01-01T01`)]
+
+	var resp TrendsResp
+	if err := decodeTrends(json.NewDecoder(strings.NewReader(partial)), &resp); err == nil {
+		t.Fatal("partial body decoded without error")
+	}
+	if err := decodeTrends(json.NewDecoder(strings.NewReader(full)), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Ts) != 2 || resp.Series["s.1.1"].Len() != 2 {
+		t.Fatalf("ts = %v, series len = %d; want 2 rows", resp.Ts, resp.Series["s.1.1"].Len())
+	}
+}
+
+// benchTrendsBody returns a /v1/trends body for a year of 15-minute data
+// over 50 points.
+func benchTrendsBody() []byte {
+	const points = 50
+	ids := make([]string, points)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("s.%d.%d", i/10+1, i%10+1)
+	}
+	idsJSON, _ := json.Marshal(ids)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"start":"2025-01-01","end":"2025-12-31","tz":"UTC","interval":"15min","aggregate":"mean","point_ids":%s,"trends":[`, idsJSON)
+	t := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := t.AddDate(1, 0, 0)
+	for row := 0; t.Before(end); row++ {
+		if row > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"ts":"%s"`, t.Format(naiveTrendLayout))
+		for i, id := range ids {
+			fmt.Fprintf(&buf, `,"%s":%d.%d`, id, 60+(row+i)%20, row%10)
+		}
+		buf.WriteByte('}')
+		t = t.Add(15 * time.Minute)
+	}
+	buf.WriteString("]}")
+	return buf.Bytes()
+}
+
+// decodeTrendsThreePass is the decode used before the streaming decoder:
+// the body is read whole, unmarshalled once for the metadata, again into
+// raw fields, and the rows a third time into one map per row.
+func decodeTrendsThreePass(body []byte) (*TrendsResp, error) {
+	var resp TrendsResp
+	raw, err := readAllRaw(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, err
+	}
+	var outer map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &outer); err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(outer["trends"], &rows); err != nil {
+		return nil, err
+	}
+	type trendRow struct {
+		ts     string
+		values map[string]interface{}
+	}
+	out := make([]trendRow, len(rows))
+	for i, row := range rows {
+		ts, _ := row["ts"].(string)
+		out[i] = trendRow{ts: ts, values: make(map[string]interface{})}
+		for k, v := range row {
+			if k != "ts" {
+				out[i].values[k] = v
+			}
+		}
+	}
+	return &resp, nil
+}
+
+// readAllRaw copies body as the old client did when decoding into a
+// json.RawMessage.
+func readAllRaw(body []byte) (json.RawMessage, error) {
+	var raw json.RawMessage
+	err := json.NewDecoder(bytes.NewReader(body)).Decode(&raw)
+	return raw, err
+}
+
+func BenchmarkDecodeTrends(b *testing.B) {
+	body := benchTrendsBody()
+
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			var resp TrendsResp
+			if err := decodeTrends(json.NewDecoder(bytes.NewReader(body)), &resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("threePass", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeTrendsThreePass(body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestTrim(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, loc) }
+
+	tests := []struct {
+		name     string
+		interval string
+		rows     []time.Time
+		from, to time.Time
+		want     []time.Time
+	}{
+		{"hour containing from", "1hr",
+			[]time.Time{at(2, 12, 0), at(2, 13, 0), at(2, 14, 0)}, at(2, 13, 10), at(2, 13, 40),
+			[]time.Time{at(2, 13, 0)}},
+		{"hour ending at from", "1hr",
+			[]time.Time{at(2, 12, 0), at(2, 13, 0), at(2, 14, 0)}, at(2, 13, 0), at(2, 14, 0),
+			[]time.Time{at(2, 13, 0), at(2, 14, 0)}},
+		{"day containing range", "1day",
+			[]time.Time{at(2, 0, 0)}, at(2, 6, 0), at(2, 12, 0),
+			[]time.Time{at(2, 0, 0)}},
+		{"23 hour spring day", "1day",
+			[]time.Time{at(7, 0, 0), at(8, 0, 0), at(9, 0, 0)}, at(8, 23, 30), at(9, 1, 0),
+			[]time.Time{at(8, 0, 0), at(9, 0, 0)}},
+		{"month containing range", "1mo",
+			[]time.Time{at(1, 0, 0)}, at(20, 0, 0), at(21, 0, 0),
+			[]time.Time{at(1, 0, 0)}},
+		{"raw drops earlier samples", "raw",
+			[]time.Time{at(2, 13, 0), at(2, 13, 10), at(2, 13, 20)}, at(2, 13, 5), at(2, 13, 20),
+			[]time.Time{at(2, 13, 10), at(2, 13, 20)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &TrendsResp{Series: map[string]*TrendSeries{"s.1.1": {}}}
+			for i, r := range tt.rows {
+				resp.Ts = append(resp.Ts, r.Format(time.RFC3339))
+				resp.Times = append(resp.Times, r)
+				resp.Series["s.1.1"].append(float64(i))
+			}
+			resp.trim(tt.from, tt.to, tt.interval)
+			if len(resp.Times) != len(tt.want) || resp.Series["s.1.1"].Len() != len(tt.want) {
+				t.Fatalf("kept %v, want %v", resp.Times, tt.want)
+			}
+			for i := range tt.want {
+				if !resp.Times[i].Equal(tt.want[i]) {
+					t.Errorf("row %d = %v, want %v", i, resp.Times[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Short ranges with an explicit coarse interval return the row whose bucket
+// covers the range rather than nothing.
+func TestQueryTrendsShortRange(t *testing.T) {
+	b := novanttest.NewBuilding(novanttest.Options{})
+	ds, _ := newTestDatasource(t, b, nil)
+	loc, _ := time.LoadLocation(b.Tz)
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 2, hour, min, 0, 0, loc) }
+
+	tests := []struct {
+		interval string
+		from, to time.Time
+		want     time.Time
+	}{
+		{"1hr", at(13, 10), at(13, 40), at(13, 0)},
+		{"1day", at(6, 0), at(12, 0), at(0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			resp := runQuery(t, ds, "trends", map[string]interface{}{"pointIds": "s.1.1", "interval": tt.interval}, tt.from, tt.to)
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if len(resp.Frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(resp.Frames))
+			}
+			f := resp.Frames[0]
+			if rows, _ := f.RowLen(); rows != 1 {
+				t.Fatalf("got %d rows, want 1", rows)
+			}
+			if got := f.Fields[0].At(0).(time.Time); !got.Equal(tt.want) {
+				t.Errorf("row at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
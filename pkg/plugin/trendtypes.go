@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"strconv"
+	"strings"
+)
+
+// pointSampleKind maps a Novant point kind to the field type its trend is
+// emitted as. It returns SampleNull for kinds it does not recognise.
+func pointSampleKind(kind string) SampleKind {
+	switch strings.ToLower(kind) {
+	case "bool", "boolean", "binary":
+		return SampleBool
+	case "num", "number", "numeric", "float", "int":
+		return SampleNumber
+	case "enum", "multistate", "str", "string", "text":
+		return SampleString
+	}
+	return SampleNull
+}
+
+// trendFieldKind picks the field type for a point's trend: the point's
+// kind from the point cache when it is known, otherwise the kind most of
+// its samples have, and numeric when the series is all null. Binary points
+// therefore stay boolean even if a source reports them as 0/1.
+func trendFieldKind(p Point, known bool, s *TrendSeries) SampleKind {
+	if known {
+		if k := pointSampleKind(p.Kind); k != SampleNull {
+			return k
+		}
+	}
+	var counts [4]int
+	if s != nil {
+		for _, k := range s.Kinds {
+			counts[k]++
+		}
+	}
+	kind := SampleNumber
+	for _, k := range []SampleKind{SampleBool, SampleString} {
+		if counts[k] > counts[kind] {
+			kind = k
+		}
+	}
+	return kind
+}
+
+// trendValues converts s to the values of a field of the given kind,
+// converting samples of other kinds where that is unambiguous and leaving
+// the rest null. With coerceBools, boolean fields become numeric 0/1 for
+// state timelines and alert conditions.
+func trendValues(s *TrendSeries, count int, kind SampleKind, coerceBools bool) interface{} {
+	if kind == SampleBool && coerceBools {
+		kind = SampleNumber
+	}
+	switch kind {
+	case SampleBool:
+		values := make([]*bool, count)
+		if s != nil {
+			for i := range values {
+				if b, ok := sampleBool(s, i); ok {
+					values[i] = &b
+				}
+			}
+		}
+		return values
+	case SampleString:
+		values := make([]*string, count)
+		if s != nil {
+			for i := range values {
+				if str, ok := sampleString(s, i); ok {
+					values[i] = &str
+				}
+			}
+		}
+		return values
+	}
+
+	// Numeric values point into the series' own backing array, so no
+	// per-sample allocation is needed for numeric samples.
+	values := make([]*float64, count)
+	if s != nil {
+		for i := range values {
+			if s.Kinds[i] == SampleNumber {
+				values[i] = &s.Nums[i]
+			} else if f, ok := sampleFloat(s, i); ok {
+				values[i] = &f
+			}
+		}
+	}
+	return values
+}
+
+func sampleBool(s *TrendSeries, i int) (bool, bool) {
+	switch v := s.Value(i).(type) {
+	case bool:
+		return v, true
+	case float64:
+		return v != 0, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	return false, false
+}
+
+func sampleString(s *TrendSeries, i int) (string, bool) {
+	switch v := s.Value(i).(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+func sampleFloat(s *TrendSeries, i int) (float64, bool) {
+	switch v := s.Value(i).(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -1,14 +1,39 @@
 import React, { useState } from 'react';
-import { Button, InlineField, SecretInput } from '@grafana/ui';
-import { AppEvents, DataSourcePluginOptionsEditorProps } from '@grafana/data';
-import { getAppEvents, getBackendSrv } from '@grafana/runtime';
+import {
+  Button,
+  CustomHeadersSettings,
+  InlineField,
+  InlineSwitch,
+  Input,
+  SecretInput,
+  SecureSocksProxySettings,
+  Select,
+  TLSAuthSettings,
+} from '@grafana/ui';
+import { AppEvents, DataSourcePluginOptionsEditorProps, SelectableValue } from '@grafana/data';
+import { config, getAppEvents, getBackendSrv } from '@grafana/runtime';
 import { NovantDataSourceOptions, NovantSecureJsonData } from '../types';
 
 type Props = DataSourcePluginOptionsEditorProps<NovantDataSourceOptions, NovantSecureJsonData>;
 
+const logLevelOptions: Array<SelectableValue<string>> = [
+  { label: 'Debug', value: 'debug', description: 'Every query, API call, and cache lookup' },
+  { label: 'Info', value: 'info' },
+  { label: 'Warn', value: 'warn', description: 'Failed queries and API calls' },
+  { label: 'Error', value: 'error' },
+];
+
+const replayModeOptions: Array<SelectableValue<string>> = [
+  { label: 'Off', value: '' },
+  { label: 'Record', value: 'record', description: 'Save every API response to the replay directory' },
+  { label: 'Replay', value: 'replay', description: 'Serve API responses from the replay directory, offline' },
+];
+
 export function ConfigEditor({ options, onOptionsChange }: Props) {
-  const { secureJsonFields, secureJsonData } = options;
+  const { jsonData, secureJsonFields, secureJsonData } = options;
   const [clearing, setClearing] = useState(false);
+  const [draftNames, setDraftNames] = useState<Record<number, string>>({});
+  const [rekey, setRekey] = useState<Record<string, boolean>>({});
 
   const onAPIKeyChange = (event: React.ChangeEvent<HTMLInputElement>) => {
     onOptionsChange({
@@ -17,6 +42,119 @@ export function ConfigEditor({ options, onOptionsChange }: Props) {
     });
   };
 
+  const onBaseUrlChange = (event: React.ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: { ...jsonData, baseUrl: event.target.value },
+    });
+  };
+
+  const onNumberChange =
+    (field: keyof NovantDataSourceOptions) => (event: React.ChangeEvent<HTMLInputElement>) => {
+      const value = event.target.value === '' ? undefined : Number(event.target.value);
+      onOptionsChange({
+        ...options,
+        jsonData: { ...jsonData, [field]: value },
+      });
+    };
+
+  const onSwitchChange =
+    (field: keyof NovantDataSourceOptions) => (event: React.ChangeEvent<HTMLInputElement>) => {
+      onOptionsChange({
+        ...options,
+        jsonData: { ...jsonData, [field]: event.target.checked },
+      });
+    };
+
+  const onLogLevelChange = (val: SelectableValue<string>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: { ...jsonData, logLevel: val.value },
+    });
+  };
+
+  const onReplayModeChange = (val: SelectableValue<string>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: { ...jsonData, replayMode: val.value || undefined },
+    });
+  };
+
+  const onReplayDirChange = (event: React.ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: { ...jsonData, replayDir: event.target.value },
+    });
+  };
+
+  const projects = jsonData.projects || [];
+
+  const setProjects = (next: typeof projects) => {
+    onOptionsChange({ ...options, jsonData: { ...jsonData, projects: next } });
+  };
+
+  const onAddProject = () => {
+    setProjects([...projects, { name: '' }]);
+  };
+
+  // Project names are edited as a draft and applied on blur: the key is
+  // stored under a secure field named after the project, so renaming moves
+  // it and an already-saved key has to be re-entered. Applying every
+  // keystroke would clear the saved key under each intermediate name.
+  const onProjectNameChange = (index: number) => (event: React.ChangeEvent<HTMLInputElement>) => {
+    setDraftNames({ ...draftNames, [index]: event.target.value });
+  };
+
+  const onProjectNameBlur = (index: number) => () => {
+    const draft = draftNames[index];
+    const rest = { ...draftNames };
+    delete rest[index];
+    setDraftNames(rest);
+    const oldName = projects[index].name;
+    if (draft === undefined || draft === oldName) {
+      return;
+    }
+    const oldKey = `apiKey.${oldName}`;
+    const newKey = `apiKey.${draft}`;
+    const next = projects.map((p, i) => (i === index ? { name: draft } : p));
+    // A key typed but not yet saved moves with the name; a saved one can't
+    // be read back, so it is dropped and flagged for re-entry.
+    const unsaved = secureJsonData?.[oldKey] || '';
+    setRekey({ ...rekey, [draft]: Boolean(secureJsonFields?.[oldKey]) && !unsaved });
+    onOptionsChange({
+      ...options,
+      jsonData: { ...jsonData, projects: next },
+      secureJsonFields: { ...secureJsonFields, [oldKey]: false },
+      secureJsonData: { ...secureJsonData, [oldKey]: '', [newKey]: unsaved },
+    });
+  };
+
+  const onProjectKeyChange = (name: string) => (event: React.ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      secureJsonData: { ...secureJsonData, [`apiKey.${name}`]: event.target.value },
+    });
+  };
+
+  const onResetProjectKey = (name: string) => () => {
+    onOptionsChange({
+      ...options,
+      secureJsonFields: { ...secureJsonFields, [`apiKey.${name}`]: false },
+      secureJsonData: { ...secureJsonData, [`apiKey.${name}`]: '' },
+    });
+  };
+
+  const onRemoveProject = (index: number) => () => {
+    const key = `apiKey.${projects[index].name}`;
+    setDraftNames({});
+    onOptionsChange({
+      ...options,
+      jsonData: { ...jsonData, projects: projects.filter((_, i) => i !== index) },
+      secureJsonFields: { ...secureJsonFields, [key]: false },
+      secureJsonData: { ...secureJsonData, [key]: '' },
+    });
+  };
+
   const onResetAPIKey = () => {
     onOptionsChange({
       ...options,
@@ -59,10 +197,207 @@ export function ConfigEditor({ options, onOptionsChange }: Props) {
           onChange={onAPIKeyChange}
         />
       </InlineField>
+      {projects.map((project, i) => (
+        <InlineField
+          key={i}
+          label={`Project ${i + 2}`}
+          labelWidth={20}
+          tooltip="An additional Novant project. Queries pick it by name; frames are labelled with the Novant project name."
+          invalid={rekey[project.name] && !secureJsonData?.[`apiKey.${project.name}`]}
+          error="Project renamed: re-enter its API key"
+        >
+          <>
+            <Input
+              value={draftNames[i] ?? project.name}
+              placeholder="name"
+              width={16}
+              onChange={onProjectNameChange(i)}
+              onBlur={onProjectNameBlur(i)}
+            />
+            <SecretInput
+              isConfigured={Boolean(secureJsonFields?.[`apiKey.${project.name}`])}
+              value={secureJsonData?.[`apiKey.${project.name}`] || ''}
+              placeholder="ak_..."
+              width={30}
+              disabled={!project.name}
+              onReset={onResetProjectKey(project.name)}
+              onChange={onProjectKeyChange(project.name)}
+            />
+            <Button variant="secondary" icon="trash-alt" aria-label="Remove project" onClick={onRemoveProject(i)} />
+          </>
+        </InlineField>
+      ))}
+      <InlineField label="" labelWidth={20}>
+        <Button variant="secondary" size="sm" icon="plus" onClick={onAddProject}>
+          Add project
+        </Button>
+      </InlineField>
+      <InlineField
+        label="API URL"
+        labelWidth={20}
+        tooltip="Novant API endpoint. Leave blank for the production API; set to point at a regional, staging, or local mock server."
+      >
+        <Input
+          value={jsonData.baseUrl || ''}
+          placeholder="https://api.novant.io"
+          width={40}
+          onChange={onBaseUrlChange}
+        />
+      </InlineField>
+      <InlineField
+        label="Max attempts"
+        labelWidth={20}
+        tooltip="Total attempts for a request that fails with a 429, 5xx, or network error, including the first. Set to 1 to disable retries. Default 4."
+      >
+        <Input
+          type="number"
+          min={1}
+          value={jsonData.retryMaxAttempts ?? ''}
+          placeholder="4"
+          width={12}
+          onChange={onNumberChange('retryMaxAttempts')}
+        />
+      </InlineField>
+      <InlineField
+        label="Retry budget (s)"
+        labelWidth={20}
+        tooltip="Maximum total time spent waiting between retries, including Retry-After delays requested by the API. Default 30."
+      >
+        <Input
+          type="number"
+          min={1}
+          value={jsonData.retryBudgetSeconds ?? ''}
+          placeholder="30"
+          width={12}
+          onChange={onNumberChange('retryBudgetSeconds')}
+        />
+      </InlineField>
+      <InlineField
+        label="Rate limit (req/s)"
+        labelWidth={20}
+        tooltip="Sustained requests per second allowed for this API key, shared by every data source using the key. Set to -1 to disable. Default 10."
+      >
+        <Input
+          type="number"
+          value={jsonData.rateLimit ?? ''}
+          placeholder="10"
+          width={12}
+          onChange={onNumberChange('rateLimit')}
+        />
+      </InlineField>
+      <InlineField label="Rate burst" labelWidth={20} tooltip="Requests allowed in a burst above the sustained rate. Default 10.">
+        <Input
+          type="number"
+          min={1}
+          value={jsonData.rateBurst ?? ''}
+          placeholder="10"
+          width={12}
+          onChange={onNumberChange('rateBurst')}
+        />
+      </InlineField>
+      <InlineField
+        label="Max concurrent"
+        labelWidth={20}
+        tooltip="Maximum in-flight requests for this API key. Set to -1 to disable. Default 8."
+      >
+        <Input
+          type="number"
+          value={jsonData.maxConcurrent ?? ''}
+          placeholder="8"
+          width={12}
+          onChange={onNumberChange('maxConcurrent')}
+        />
+      </InlineField>
+      <InlineField
+        label="Point batch size"
+        labelWidth={20}
+        tooltip="Most point IDs sent in one values or trends request. Longer lists, e.g. an All template variable, are split into batches fetched concurrently and merged. Default 100."
+      >
+        <Input
+          type="number"
+          min={1}
+          value={jsonData.pointBatchSize ?? ''}
+          placeholder="100"
+          width={12}
+          onChange={onNumberChange('pointBatchSize')}
+        />
+      </InlineField>
+      <InlineField
+        label="Trends cache (MiB)"
+        labelWidth={20}
+        tooltip="Memory budget for cached trend history. Closed days are kept for up to a week; the current day is refreshed every 30s. Set to -1 to disable. Default 64."
+      >
+        <Input
+          type="number"
+          value={jsonData.trendCacheMB ?? ''}
+          placeholder="64"
+          width={12}
+          onChange={onNumberChange('trendCacheMB')}
+        />
+      </InlineField>
+      <InlineField
+        label="Timeout (s)"
+        labelWidth={20}
+        tooltip="HTTP request timeout for each Novant API call. Default 30."
+      >
+        <Input
+          type="number"
+          min={1}
+          value={jsonData.timeout ?? ''}
+          placeholder="30"
+          width={12}
+          onChange={onNumberChange('timeout')}
+        />
+      </InlineField>
+      <InlineField label="With CA cert" labelWidth={20} tooltip="Verify the API certificate against a custom root CA">
+        <InlineSwitch value={jsonData.tlsAuthWithCACert || false} onChange={onSwitchChange('tlsAuthWithCACert')} />
+      </InlineField>
+      <InlineField label="TLS client auth" labelWidth={20} tooltip="Present a client certificate (mTLS)">
+        <InlineSwitch value={jsonData.tlsAuth || false} onChange={onSwitchChange('tlsAuth')} />
+      </InlineField>
+      <InlineField label="Skip TLS verify" labelWidth={20}>
+        <InlineSwitch value={jsonData.tlsSkipVerify || false} onChange={onSwitchChange('tlsSkipVerify')} />
+      </InlineField>
+      {(jsonData.tlsAuth || jsonData.tlsAuthWithCACert) && (
+        <TLSAuthSettings dataSourceConfig={options} onChange={onOptionsChange} />
+      )}
+      <CustomHeadersSettings dataSourceConfig={options} onChange={onOptionsChange} />
+      {config.secureSocksDSProxyEnabled && (
+        <SecureSocksProxySettings options={options} onOptionsChange={onOptionsChange} />
+      )}
+      <InlineField
+        label="Log level"
+        labelWidth={20}
+        tooltip="Minimum level of plugin request logs. Debug lines also need Grafana's own log level set to debug. API keys are never logged."
+      >
+        <Select options={logLevelOptions} value={jsonData.logLevel || 'info'} onChange={onLogLevelChange} width={20} />
+      </InlineField>
+      <InlineField
+        label="Record/replay"
+        labelWidth={20}
+        tooltip="For reproducing issues offline. Record saves API responses (with API keys redacted) to the replay directory on the Grafana server; Replay serves them without network access. Overridden by NOVANT_REPLAY_MODE and NOVANT_REPLAY_DIR."
+      >
+        <Select
+          options={replayModeOptions}
+          value={jsonData.replayMode || ''}
+          onChange={onReplayModeChange}
+          width={20}
+        />
+      </InlineField>
+      {jsonData.replayMode && (
+        <InlineField label="Replay directory" labelWidth={20}>
+          <Input
+            value={jsonData.replayDir || ''}
+            placeholder="/var/lib/grafana/novant-fixtures"
+            width={40}
+            onChange={onReplayDirChange}
+          />
+        </InlineField>
+      )}
       <InlineField
         label="Cache"
         labelWidth={20}
-        tooltip="The plugin caches point name metadata (24h) and live value responses (30s) to reduce API calls. Click to clear all cached data and force a refresh on the next query. Only takes effect after the data source has been saved."
+        tooltip="The plugin caches point name metadata (1h), live value responses (30s), and trend history (closed days up to a week) to reduce API calls. Click to clear all cached data and force a refresh on the next query. Only takes effect after the data source has been saved."
       >
         <Button
           variant="secondary"
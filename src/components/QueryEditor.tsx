@@ -17,7 +17,7 @@ const queryTypeOptions: Array<SelectableValue<QueryType>> = [
 ];
 
 const intervalOptions: Array<SelectableValue<string>> = [
-  { label: 'Auto', value: 'auto' },
+  { label: 'Auto', value: 'auto', description: 'Picked from the panel width and time range' },
   { label: '5 min', value: '5min' },
   { label: '15 min', value: '15min' },
   { label: '30 min', value: '30min' },
@@ -27,6 +27,13 @@ const intervalOptions: Array<SelectableValue<string>> = [
   { label: 'Raw', value: 'raw' },
 ];
 
+const formatOptions: Array<SelectableValue<string>> = [
+  { label: 'Wide', value: 'wide', description: 'One frame, one column per point' },
+  { label: 'Long', value: 'long', description: 'time, point_id, name, value rows' },
+  { label: 'Multi', value: 'multi', description: 'One frame per point' },
+  { label: 'Alert', value: 'alert', description: 'One numeric frame per point, labelled with its equipment' },
+];
+
 const aggregateOptions: Array<SelectableValue<string>> = [
   { label: 'Auto', value: 'auto' },
   { label: 'Mean', value: 'mean' },
@@ -60,6 +67,11 @@ export function QueryEditor({ query, onChange, onRunQuery }: Props) {
     onRunQuery();
   };
 
+  const onCoerceBoolsChange = (event: React.ChangeEvent<HTMLInputElement>) => {
+    onChange({ ...query, coerceBools: event.target.checked });
+    onRunQuery();
+  };
+
   const { queryType } = query;
 
   return (
@@ -72,6 +84,19 @@ export function QueryEditor({ query, onChange, onRunQuery }: Props) {
           width={25}
         />
       </InlineField>
+      <InlineField
+        label="Project"
+        labelWidth={14}
+        tooltip="Project name(s) configured on the data source, comma-separated. Leave blank for the first project; use * to query all projects."
+      >
+        <Input
+          value={query.project || ''}
+          onChange={onFieldChange('project')}
+          onBlur={onFieldBlur}
+          placeholder="default"
+          width={25}
+        />
+      </InlineField>
 
       {queryType === 'zones' && (
         <InlineField label="Zone IDs" labelWidth={14} tooltip="Comma-separated zone IDs (optional)">
@@ -209,6 +234,25 @@ export function QueryEditor({ query, onChange, onRunQuery }: Props) {
               width={16}
             />
           </InlineField>
+          <InlineField
+            label="Format"
+            labelWidth={14}
+            tooltip="Frame layout. Long suits the Partition by values transform; Multi gives one series per point; Alert adds source, asset, space and zone labels for alert rules. Long and Alert format values are numeric (booleans as 0/1)."
+          >
+            <Select
+              options={formatOptions}
+              value={query.format || 'wide'}
+              onChange={onSelectChange('format')}
+              width={16}
+            />
+          </InlineField>
+          <InlineField
+            label="Bools as 0/1"
+            labelWidth={14}
+            tooltip="Return boolean points (e.g. fan run, occupancy) as numeric 0/1 instead of true/false, for state timelines and alert conditions"
+          >
+            <InlineSwitch value={query.coerceBools || false} onChange={onCoerceBoolsChange} />
+          </InlineField>
         </>
       )}
     </>
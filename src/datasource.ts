@@ -24,6 +24,7 @@ export class DataSource extends DataSourceWithBackend<NovantQuery, NovantDataSou
       spaceIds: query.spaceIds ? templateSrv.replace(query.spaceIds, scopedVars) : query.spaceIds,
       assetIds: query.assetIds ? templateSrv.replace(query.assetIds, scopedVars) : query.assetIds,
       sourceIds: query.sourceIds ? templateSrv.replace(query.sourceIds, scopedVars) : query.sourceIds,
+      project: query.project ? templateSrv.replace(query.project, scopedVars, 'csv') : query.project,
     };
   }
 }
@@ -4,6 +4,8 @@ export type QueryType = 'zones' | 'spaces' | 'assets' | 'sources' | 'points' | '
 
 export interface NovantQuery extends DataQuery {
   queryType: QueryType;
+  // Project name(s) to query; empty for the first project, '*' for all
+  project?: string;
   // Entity filters
   zoneIds?: string;
   spaceIds?: string;
@@ -20,6 +22,10 @@ export interface NovantQuery extends DataQuery {
   // Trend options
   interval?: string;
   aggregate?: string;
+  // Trend frame layout: wide | long | multi | alert
+  format?: string;
+  // Return boolean trend points as numeric 0/1
+  coerceBools?: boolean;
 }
 
 export const DEFAULT_QUERY: Partial<NovantQuery> = {
@@ -28,8 +34,40 @@ export const DEFAULT_QUERY: Partial<NovantQuery> = {
   aggregate: 'auto',
 };
 
-export interface NovantDataSourceOptions extends DataSourceJsonData {}
+export interface NovantDataSourceOptions extends DataSourceJsonData {
+  // Novant API endpoint override; defaults to https://api.novant.io
+  baseUrl?: string;
+  // Retry policy for transient API failures (429 / 5xx / network)
+  retryMaxAttempts?: number;
+  retryBudgetSeconds?: number;
+  // Client-side limits shared by every data source using the same API key
+  rateLimit?: number;
+  rateBurst?: number;
+  maxConcurrent?: number;
+  // Max point IDs per values/trends request; longer lists are batched
+  pointBatchSize?: number;
+  // Trends cache memory budget in MiB; negative disables the cache
+  trendCacheMB?: number;
+  // Additional Novant projects; each key lives in secureJsonData['apiKey.<name>']
+  projects?: NovantProject[];
+  // Minimum plugin request log level: debug | info | warn | error
+  logLevel?: string;
+  // API traffic record/replay: '' | record | replay, plus fixture directory
+  replayMode?: string;
+  replayDir?: string;
+  // Standard Grafana HTTP settings, read by the backend through the SDK
+  timeout?: number;
+  tlsAuth?: boolean;
+  tlsAuthWithCACert?: boolean;
+  tlsSkipVerify?: boolean;
+}
+
+export interface NovantProject {
+  name: string;
+}
 
 export interface NovantSecureJsonData {
   apiKey?: string;
+  // Per-project keys, stored as 'apiKey.<project name>'
+  [key: string]: string | undefined;
 }